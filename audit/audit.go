@@ -0,0 +1,68 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+
+	chiMiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/qustavo/dotsql"
+
+	"digitales-filmmanagement-backend/globals"
+)
+
+// Action describes the kind of change recorded in the audit log
+type Action string
+
+const (
+	// ActionCreate is recorded if an entity has been created
+	ActionCreate Action = "create"
+	// ActionUpdate is recorded if an entity has been changed
+	ActionUpdate Action = "update"
+	// ActionDelete is recorded if an entity has been deleted or revoked
+	ActionDelete Action = "delete"
+)
+
+// Record appends an entry to the audit log. The actor, the device and the
+// request id are taken from the request context. The states of the entity
+// before and after the change are stored as JSON and may be nil if the entity
+// did not exist before or does not exist anymore.
+//
+// The entry should be written using the database transaction which contains
+// the change itself, since the change is not recorded otherwise if the
+// transaction is rolled back
+func Record(ctx context.Context, db dotsql.ExecerContext, action Action, entity string, entityId string,
+	before interface{}, after interface{}) error {
+	beforeJson, err := marshalState(before)
+	if err != nil {
+		return err
+	}
+	afterJson, err := marshalState(after)
+	if err != nil {
+		return err
+	}
+
+	actor, _ := ctx.Value("userId").(string)
+	actorName, _ := ctx.Value("user").(string)
+	var device *string
+	if deviceId, isDevice := ctx.Value("device").(string); isDevice {
+		device = &deviceId
+	}
+	_, err = globals.SqlQueries.ExecContext(ctx, db, "insert-audit-entry",
+		actor, actorName, device, chiMiddleware.GetReqID(ctx), string(action), entity, entityId,
+		beforeJson, afterJson)
+	return err
+}
+
+// marshalState converts the state of an entity into JSON. If the state is
+// nil, nil is returned to store NULL in the database
+func marshalState(state interface{}) (*string, error) {
+	if state == nil {
+		return nil, nil
+	}
+	encoded, err := json.Marshal(state)
+	if err != nil {
+		return nil, err
+	}
+	value := string(encoded)
+	return &value, nil
+}
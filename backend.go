@@ -1,35 +1,94 @@
 package main
 
 import (
+	"context"
+	"digitales-filmmanagement-backend/devauth"
+	"digitales-filmmanagement-backend/jobs"
+	"digitales-filmmanagement-backend/metrics"
 	"digitales-filmmanagement-backend/routes"
+	"digitales-filmmanagement-backend/tracing"
+	"errors"
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
+	"github.com/ggicci/httpin"
 	"github.com/go-chi/chi/v5"
 	"github.com/rs/zerolog/log"
 
 	"digitales-filmmanagement-backend/globals"
 	"digitales-filmmanagement-backend/middleware"
+	"digitales-filmmanagement-backend/types"
 )
 import chiMiddleware "github.com/go-chi/chi/v5/middleware"
 
 // this function configures and starts the http server
 func main() {
+	// start the fake authorization server if the development authentication
+	// mode has been enabled explicitly
+	if globals.Configuration.DevAuth.IsEnabled() {
+		devAuthServer, err := devauth.Start(&globals.Configuration.DevAuth)
+		if err != nil {
+			log.Fatal().Err(err).Msg("unable to start the dev auth server")
+		}
+		defer devAuthServer.Close()
+	}
+
+	// configure the exporter of the spans recorded for the requests, the
+	// database queries and the requests to the authorization servers
+	stopTracing, err := tracing.Start(context.Background(), globals.Configuration.Tracing)
+	if err != nil {
+		log.Fatal().Err(err).Msg("unable to set up the tracing")
+	}
+
 	// create a main router handling the different routes for the backend
 	router := chi.NewRouter()
 	// now enable some middleware globally which is used to identify requests
 	// better in case of abuse or debugging
 	router.Use(chiMiddleware.RealIP)
 	router.Use(chiMiddleware.RequestID)
-	router.Use(chiMiddleware.Logger)
-	router.Use(middleware.NativeErrorHandler)
-	router.Use(middleware.APIErrorHandler(globals.Errors))
-	router.Use(middleware.UserInfo(globals.Configuration.OIDC))
-	router.Mount("/registerItems", registerItemRouter())
-	router.Mount("/registers", registerRouter())
-	router.Mount("/statistics", routes.StatisticsRouter())
+	router.Use(middleware.AccessLog)
+	router.Use(middleware.Metrics)
+	router.Use(middleware.Tracing)
+	router.Use(middleware.ErrorHandler(globals.Errors, globals.Configuration.Localization.Languages,
+		globals.Configuration.ErrorResponses.ExposesDetails()))
+	router.NotFound(middleware.NotFound)
+	router.MethodNotAllowed(middleware.MethodNotAllowed)
+	httpin.ReplaceDefaultErrorHandler(middleware.InvalidInput)
+	// the health endpoints are public, since the health checks of the
+	// container runtime are unable to authenticate
+	router.Get("/healthz", middleware.Handle(routes.Liveness))
+	router.Get("/readyz", middleware.Handle(routes.Readiness))
+	// the metrics are scraped by prometheus, which authenticates using a
+	// static token. without a token, the endpoint is not served at all
+	if globals.Configuration.Metrics.IsEnabled() {
+		metrics.RegisterDatabases(globals.Database, globals.WpDatabase)
+		router.With(middleware.RequireBearerToken(*globals.Configuration.Metrics.Token)).
+			Handle("/metrics", metrics.Handler())
+	}
+	// the calendar feed is public, since calendar clients are unable to
+	// authenticate using open id connect
+	router.Mount("/calendar", routes.CalendarRouter())
+	// every other route requires a valid authorization
+	router.Group(func(router chi.Router) {
+		router.Use(middleware.UserInfo(globals.Configuration.IdentityProviders()))
+		router.Mount("/registerItems", registerItemRouter())
+		router.Mount("/registers", registerRouter())
+		router.Mount("/statistics", routes.StatisticsRouter())
+		router.Mount("/venues", routes.VenuesRouter())
+		router.Mount("/screenings", routes.ScreeningsRouter())
+		router.Mount("/tickets", routes.TicketsRouter())
+		router.Mount("/checkins", routes.CheckInsRouter())
+		router.Mount("/shifts", routes.ShiftsRouter())
+		router.Mount("/monitoring", routes.MonitoringRouter())
+		router.Mount("/devices", routes.DevicesRouter())
+		router.Mount("/device", routes.DeviceRouter())
+		router.Mount("/staff", routes.StaffRouter())
+		router.Mount("/audit", routes.AuditRouter())
+	})
 
 	server := &http.Server{
 		Addr:         "0.0.0.0:8000",
@@ -39,31 +98,81 @@ func main() {
 		Handler:      router,
 	}
 
-	// Start the server and log errors that happen while running it
+	// Start the server and log errors that happen while running it. The
+	// server returns http.ErrServerClosed once it is shut down
 	go func() {
-		if err := server.ListenAndServe(); err != nil {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			log.Fatal().Err(err).Msg("An error occurred while starting the http server")
 		}
 	}()
 
-	// Set up the signal handling to allow the server to shut down gracefully
+	// Start the background jobs. They are stopped once the server shuts down
+	jobContext, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	var runningJobs sync.WaitGroup
+	runningJobs.Add(2)
+	go func() {
+		defer runningJobs.Done()
+		jobs.ReleaseReservations(jobContext)
+	}()
+	go func() {
+		defer runningJobs.Done()
+		jobs.SyncAttendance(jobContext)
+	}()
 
+	// Set up the signal handling to allow the server to shut down gracefully.
+	// Docker sends SIGTERM when stopping the container
 	cancelSignal := make(chan os.Signal, 1)
-	signal.Notify(cancelSignal, os.Interrupt)
+	signal.Notify(cancelSignal, os.Interrupt, syscall.SIGTERM)
 
 	// Block further code execution until the shutdown signal was received
-	<-cancelSignal
+	receivedSignal := <-cancelSignal
+	// a second signal terminates the server immediately
+	signal.Stop(cancelSignal)
+	log.Info().Str("signal", receivedSignal.String()).Msg("shutting down. waiting for running requests to finish")
+
+	// stop accepting new requests and wait for the running requests, e.g.
+	// ticket sales, to finish before closing the database connections
+	drainTimeout := globals.Configuration.Server.Drain()
+	shutdownContext, cancelShutdown := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancelShutdown()
+	if err := server.Shutdown(shutdownContext); err != nil {
+		log.Warn().Err(err).Dur("drainTimeout", drainTimeout).Msg("requests still running after the drain timeout. closing the connections")
+		if err := server.Close(); err != nil {
+			log.Warn().Err(err).Msg("unable to close the http server")
+		}
+	}
+
+	// now stop the background jobs and wait until their current run finished
+	stopJobs()
+	runningJobs.Wait()
+
+	if err := globals.Database.Close(); err != nil {
+		log.Warn().Err(err).Msg("unable to close database connection")
+	}
+	if err := globals.WpDatabase.Close(); err != nil {
+		log.Warn().Err(err).Msg("unable to close wordpress database connection")
+	}
+	// now export the spans of the last requests
+	flushContext, cancelFlush := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelFlush()
+	if err := stopTracing(flushContext); err != nil {
+		log.Warn().Err(err).Msg("unable to export the remaining spans")
+	}
+	log.Info().Msg("server stopped")
 }
 
 func registerItemRouter() http.Handler {
 	r := chi.NewRouter()
-	r.Get("/", routes.GetAllRegisterItems)
+	r.With(middleware.RequireRole(types.RoleBar, types.RoleDoor, types.RoleTreasurer)).
+		Get("/", middleware.Handle(routes.GetAllRegisterItems))
 	return r
 }
 
 func registerRouter() http.Handler {
 	r := chi.NewRouter()
-	r.Get("/", routes.GetAllRegisters)
-	r.Post("/{registerId}/transactions", routes.NewRegisterTransaction)
+	r.Use(middleware.RequireRole(types.RoleBar, types.RoleDoor, types.RoleTreasurer))
+	r.Get("/", middleware.Handle(routes.GetAllRegisters))
+	r.Post("/{registerId}/transactions", middleware.Handle(routes.NewRegisterTransaction))
 	return r
 }
@@ -0,0 +1,155 @@
+package calendar
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	// the timezone database is embedded since the docker image does not
+	// contain it
+	_ "time/tzdata"
+)
+
+// TimeZone contains the name of the time zone in which all events are written
+const TimeZone = "Europe/Berlin"
+
+// Location is the time zone in which all events are written
+var Location, _ = time.LoadLocation(TimeZone)
+
+// vTimeZone contains the definition of the Europe/Berlin time zone using the
+// daylight saving rules in effect since 1996
+const vTimeZone = `BEGIN:VTIMEZONE
+TZID:Europe/Berlin
+X-LIC-LOCATION:Europe/Berlin
+BEGIN:DAYLIGHT
+TZOFFSETFROM:+0100
+TZOFFSETTO:+0200
+TZNAME:CEST
+DTSTART:19700329T020000
+RRULE:FREQ=YEARLY;BYMONTH=3;BYDAY=-1SU
+END:DAYLIGHT
+BEGIN:STANDARD
+TZOFFSETFROM:+0200
+TZOFFSETTO:+0100
+TZNAME:CET
+DTSTART:19701025T030000
+RRULE:FREQ=YEARLY;BYMONTH=10;BYDAY=-1SU
+END:STANDARD
+END:VTIMEZONE`
+
+// maxLineLength contains the maximal length of a content line in octets
+// before it needs to be folded
+const maxLineLength = 75
+
+// Event represents a single VEVENT of a calendar
+type Event struct {
+	// UID contains the globally unique and stable identifier of the event.
+	// Calendar clients use it to replace older versions of the event
+	UID string
+	// Summary contains the title of the event
+	Summary string
+	// Description contains an optional long-text description of the event
+	Description string
+	// Location contains the optional location of the event
+	Location string
+	// Categories contains optional categories of the event
+	Categories []string
+	// Start contains the start of the event
+	Start time.Time
+	// End contains the end of the event
+	End time.Time
+	// Modified contains the time of the last modification of the event. It
+	// is used for the DTSTAMP and LAST-MODIFIED properties, which keeps the
+	// generated calendar identical as long as no event changes
+	Modified time.Time
+}
+
+// Write renders the events as RFC 5545 calendar with the supplied name
+func Write(w io.Writer, name string, events []Event) error {
+	writer := bufio.NewWriter(w)
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//Unikino Gegenlicht//Digitales Filmmanagement//DE",
+		"CALSCALE:GREGORIAN",
+		"X-WR-CALNAME:" + escape(name),
+		"X-WR-TIMEZONE:" + TimeZone,
+	}
+	lines = append(lines, strings.Split(vTimeZone, "\n")...)
+	for _, event := range events {
+		lines = append(lines,
+			"BEGIN:VEVENT",
+			"UID:"+escape(event.UID),
+			"DTSTAMP:"+formatUTC(event.Modified),
+			"LAST-MODIFIED:"+formatUTC(event.Modified),
+			"DTSTART;TZID="+TimeZone+":"+formatLocal(event.Start),
+			"DTEND;TZID="+TimeZone+":"+formatLocal(event.End),
+			"SUMMARY:"+escape(event.Summary),
+		)
+		if event.Description != "" {
+			lines = append(lines, "DESCRIPTION:"+escape(event.Description))
+		}
+		if event.Location != "" {
+			lines = append(lines, "LOCATION:"+escape(event.Location))
+		}
+		if len(event.Categories) > 0 {
+			categories := make([]string, len(event.Categories))
+			for i, category := range event.Categories {
+				categories[i] = escape(category)
+			}
+			lines = append(lines, "CATEGORIES:"+strings.Join(categories, ","))
+		}
+		lines = append(lines, "END:VEVENT")
+	}
+	lines = append(lines, "END:VCALENDAR")
+
+	for _, line := range lines {
+		if _, err := writer.WriteString(fold(line)); err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
+}
+
+// formatUTC formats the time as UTC date-time value
+func formatUTC(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// formatLocal formats the time as local date-time value in the calendar's
+// time zone
+func formatLocal(t time.Time) string {
+	return t.In(Location).Format("20060102T150405")
+}
+
+// escape escapes the characters which have a special meaning in text values
+func escape(text string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+	).Replace(text)
+}
+
+// fold splits a content line into lines of at most 75 octets and terminates
+// every line with CRLF. Continuation lines start with a single space and
+// multi-octet characters are never split
+func fold(line string) string {
+	var folded strings.Builder
+	length := 0
+	for _, r := range line {
+		size := utf8.RuneLen(r)
+		if length+size > maxLineLength {
+			folded.WriteString("\r\n ")
+			length = 1
+		}
+		folded.WriteRune(r)
+		length += size
+	}
+	folded.WriteString("\r\n")
+	return folded.String()
+}
@@ -0,0 +1,56 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// CalendarConfiguration contains the configuration of the iCalendar feed
+// generated from the screenings published on the WordPress website.
+type CalendarConfiguration struct {
+	// UidDomain contains the domain appended to the UIDs of the calendar
+	// events. It should not be changed after calendars have been subscribed,
+	// since calendar clients would otherwise duplicate every event. If not
+	// set, "filmmanagement.gegenlicht.net" is used
+	UidDomain *string `toml:"uidDomain"`
+
+	// ScreeningDuration contains the duration of a screening as Go duration
+	// string. It is used to calculate the end of the calendar events. If not
+	// set, a screening lasts two hours
+	ScreeningDuration *string `toml:"screeningDuration"`
+
+	// History contains the duration for which past screenings are kept in the
+	// feed. If not set, screenings of the last 90 days are included
+	History *string `toml:"history"`
+
+	screeningDuration time.Duration
+	history           time.Duration
+}
+
+// Validate parses the configured durations and sets the default values for
+// every setting that has not been configured
+func (c *CalendarConfiguration) Validate() (err error) {
+	setDefault(&c.UidDomain, "filmmanagement.gegenlicht.net")
+	c.screeningDuration, err = parseOptionalDuration(c.ScreeningDuration, 2*time.Hour)
+	if err != nil || c.screeningDuration <= 0 {
+		return errors.Join(ErrInvalidScreeningDuration, err)
+	}
+	c.history, err = parseOptionalDuration(c.History, 90*24*time.Hour)
+	if err != nil || c.history < 0 {
+		return errors.Join(ErrInvalidCalendarHistory, err)
+	}
+	*c.UidDomain = strings.TrimSpace(*c.UidDomain)
+	return nil
+}
+
+// Duration returns the validated duration of a screening
+func (c *CalendarConfiguration) Duration() time.Duration {
+	return c.screeningDuration
+}
+
+// HistoryDuration returns the validated duration for which past screenings
+// are kept in the feed
+func (c *CalendarConfiguration) HistoryDuration() time.Duration {
+	return c.history
+}
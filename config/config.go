@@ -1,10 +1,119 @@
 package config
 
+import (
+	"fmt"
+	"strings"
+)
+
 // Configuration contains all sub-configurations and puts them into one struct
 // to allow the parsing of a configuration.toml file which needs to be supplied
 // to the documented location (see README or INSTALLATION)
 type Configuration struct {
-	OIDC      OpenIdConnectConfiguration `toml:"oidc"`
-	Database  DbConfiguration            `toml:"database"`
-	WordPress WpDbConfiguration          `toml:"wordpress"`
+	OIDC           OpenIdConnectConfiguration   `toml:"oidc"`
+	OIDCProviders  []OpenIdConnectConfiguration `toml:"oidcProviders"`
+	Database       DbConfiguration              `toml:"database"`
+	WordPress      WpDbConfiguration            `toml:"wordpress"`
+	Reservations   ReservationConfiguration     `toml:"reservations"`
+	Tickets        TicketConfiguration          `toml:"tickets"`
+	Calendar       CalendarConfiguration        `toml:"calendar"`
+	Devices        DeviceConfiguration          `toml:"devices"`
+	DevAuth        DevAuthConfiguration         `toml:"devAuth"`
+	Localization   LocalizationConfiguration    `toml:"localization"`
+	ErrorResponses ErrorResponseConfiguration   `toml:"errorResponses"`
+	Server         ServerConfiguration          `toml:"server"`
+	Metrics        MetricsConfiguration         `toml:"metrics"`
+	Tracing        TracingConfiguration         `toml:"tracing"`
+}
+
+// IdentityProviders returns every configured authorization server. The
+// server configured in the "oidc" table is returned first, followed by the
+// servers of the "oidcProviders" list and the fake authorization server of
+// the development authentication mode
+//
+// Example:
+//
+//	[oidc]
+//	discoveryEndpoint = "https://id.gegenlicht.net/.well-known/openid-configuration"
+//
+//	[[oidcProviders]]
+//	name = "partner"
+//	discoveryEndpoint = "https://login.partner-kino.de/.well-known/openid-configuration"
+//	groupsClaim = "roles"
+func (c *Configuration) IdentityProviders() []*OpenIdConnectConfiguration {
+	var providers []*OpenIdConnectConfiguration
+	if c.OIDC.IsConfigured() {
+		providers = append(providers, &c.OIDC)
+	}
+	for i := range c.OIDCProviders {
+		providers = append(providers, &c.OIDCProviders[i])
+	}
+	if c.DevAuth.IsEnabled() {
+		providers = append(providers, c.DevAuth.Provider())
+	}
+	return providers
+}
+
+// ValidateIdentityProviders validates every configured authorization server
+// and checks that the servers can be told apart by their name, issuer and
+// token prefix. Since an opaque token is only sent to a single server, at
+// most one server may accept the opaque tokens without a known prefix
+func (c *Configuration) ValidateIdentityProviders() error {
+	if err := c.DevAuth.Validate(); err != nil {
+		return err
+	}
+	providers := c.IdentityProviders()
+	if len(providers) == 0 {
+		return ErrEmptyOpenIdConnectConfig
+	}
+	names := make(map[string]bool)
+	issuers := make(map[string]bool)
+	for i, provider := range providers {
+		if provider == c.DevAuth.Provider() {
+			// the fake authorization server has been validated already
+			if names[*provider.Name] {
+				return fmt.Errorf("%w: %s", ErrDuplicateOpenIdConnectProvider, *provider.Name)
+			}
+			continue
+		}
+		if provider.Name == nil && (i > 0 || !c.OIDC.IsConfigured()) {
+			return ErrUnnamedOpenIdConnectProvider
+		}
+		if err := provider.Validate(); err != nil {
+			return fmt.Errorf("%s: %w", provider.DisplayName(), err)
+		}
+		if provider.Name != nil {
+			if names[*provider.Name] {
+				return fmt.Errorf("%w: %s", ErrDuplicateOpenIdConnectProvider, *provider.Name)
+			}
+			names[*provider.Name] = true
+		}
+		if provider.Issuer != nil {
+			if issuers[*provider.Issuer] {
+				return fmt.Errorf("%w: %s", ErrDuplicateOpenIdConnectProvider, *provider.Issuer)
+			}
+			issuers[*provider.Issuer] = true
+		}
+	}
+	var fallbacks int
+	for i, provider := range providers {
+		if provider.IsOpaqueTokenFallback() {
+			fallbacks++
+		}
+		if provider.TokenPrefix == nil {
+			continue
+		}
+		for _, other := range providers[i+1:] {
+			if other.TokenPrefix == nil {
+				continue
+			}
+			if strings.HasPrefix(*provider.TokenPrefix, *other.TokenPrefix) ||
+				strings.HasPrefix(*other.TokenPrefix, *provider.TokenPrefix) {
+				return fmt.Errorf("%w: %s", ErrAmbiguousTokenPrefix, *provider.TokenPrefix)
+			}
+		}
+	}
+	if fallbacks > 1 {
+		return ErrMultipleOpaqueTokenFallbacks
+	}
+	return nil
 }
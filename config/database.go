@@ -12,7 +12,7 @@ type DbConfiguration struct {
 	Host      *string `toml:"host"`
 	Port      *string `toml:"port"`
 	User      *string `toml:"user"`
-	Password  *string `toml:"password"`
+	Password  *string `toml:"password" secret:"true"`
 	validated bool
 }
 
@@ -0,0 +1,139 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"digitales-filmmanagement-backend/types"
+)
+
+// DevAuthTokenPrefix is the prefix of the tokens of the test users. Only
+// tokens with this prefix are sent to the fake authorization server
+const DevAuthTokenPrefix = "dev-"
+
+// DevAuthConfiguration contains the configuration of the development
+// authentication mode. If enabled, the backend starts a fake authorization
+// server on the loopback interface which accepts the tokens of the configured
+// test users. The mode is meant for offline development and refuses to start
+// unless it is explicitly enabled and bound to a loopback address.
+//
+// The fake authorization server only answers userinfo requests. It neither
+// issues tokens nor publishes a key set, therefore the local validation of
+// JWT access tokens cannot be tested with this mode.
+//
+// Example:
+//
+//	[devAuth]
+//	enabled = true
+//
+//	[[devAuth.users]]
+//	token = "dev-anna"
+//	name = "Anna"
+//	roles = ["bar", "door"]
+type DevAuthConfiguration struct {
+	// Enabled needs to be set to true to enable the development
+	// authentication mode
+	Enabled *bool `toml:"enabled"`
+
+	// Address contains the loopback address and port the fake authorization
+	// server listens on. If not set, "127.0.0.1:8001" is used
+	Address *string `toml:"address"`
+
+	// Users contains the test users accepted by the fake authorization server.
+	// The tokens need to start with DevAuthTokenPrefix. If not set, a user is
+	// created for every role with the token "dev-" and the name of the role
+	Users []DevAuthUser `toml:"users"`
+
+	provider OpenIdConnectConfiguration
+}
+
+// DevAuthUser describes a test user of the development authentication mode
+type DevAuthUser struct {
+	// Token contains the bearer token used to authenticate as the user
+	Token string `toml:"token" json:"-" secret:"true"`
+	// Subject contains the subject of the user. If not set, the token is used
+	Subject string `toml:"subject" json:"sub"`
+	// Name contains the display name of the user
+	Name string `toml:"name" json:"name"`
+	// Roles contains the roles granted to the user
+	Roles []types.Role `toml:"roles" json:"groups"`
+}
+
+// IsEnabled reports if the development authentication mode has been enabled
+func (c *DevAuthConfiguration) IsEnabled() bool {
+	return c.Enabled != nil && *c.Enabled
+}
+
+// Validate checks that the fake authorization server only listens on a
+// loopback address and that the test users are valid. Afterwards, the
+// configuration of the fake authorization server is prepared
+func (c *DevAuthConfiguration) Validate() error {
+	if !c.IsEnabled() {
+		return nil
+	}
+	setDefault(&c.Address, "127.0.0.1:8001")
+	host, _, err := net.SplitHostPort(*c.Address)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidDevAuthAddress, *c.Address)
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return fmt.Errorf("%w: %s", ErrDevAuthNotLoopback, *c.Address)
+	}
+
+	if len(c.Users) == 0 {
+		for _, role := range types.Roles {
+			c.Users = append(c.Users, DevAuthUser{
+				Token: DevAuthTokenPrefix + string(role),
+				Name:  strings.ToUpper(string(role[:1])) + string(role[1:]),
+				Roles: []types.Role{role},
+			})
+		}
+	}
+	tokens := make(map[string]bool)
+	for i, user := range c.Users {
+		validToken := strings.HasPrefix(user.Token, DevAuthTokenPrefix) && len(user.Token) > len(DevAuthTokenPrefix)
+		if !validToken || strings.TrimSpace(user.Name) == "" || tokens[user.Token] {
+			return fmt.Errorf("%w: %s", ErrInvalidDevAuthUser, user.Name)
+		}
+		tokens[user.Token] = true
+		if user.Subject == "" {
+			c.Users[i].Subject = user.Token
+		}
+		for _, role := range user.Roles {
+			if !role.IsKnown() {
+				return fmt.Errorf("%w: %s", ErrUnknownRole, role)
+			}
+		}
+	}
+
+	// the fake authorization server puts the roles of the test users into
+	// the groups claim, therefore every role is granted to the group with
+	// the same name
+	name := "dev"
+	tokenPrefix := DevAuthTokenPrefix
+	userInfoEndpoint := "http://" + *c.Address + "/userinfo"
+	roles := make(map[types.Role][]string)
+	for _, role := range types.Roles {
+		roles[role] = []string{string(role)}
+	}
+	c.provider = OpenIdConnectConfiguration{
+		Name:             &name,
+		UserInfoEndpoint: &userInfoEndpoint,
+		TokenPrefix:      &tokenPrefix,
+		Roles:            roles,
+		allowInsecure:    true,
+	}
+	return c.provider.Validate()
+}
+
+// Provider returns the configuration of the fake authorization server used
+// by the authentication middleware
+func (c *DevAuthConfiguration) Provider() *OpenIdConnectConfiguration {
+	return &c.provider
+}
+
+// Issuer returns the address of the fake authorization server
+func (c *DevAuthConfiguration) Issuer() string {
+	return "http://" + *c.Address
+}
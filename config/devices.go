@@ -0,0 +1,67 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// DeviceConfiguration contains the configuration for the device credentials
+// used by the registers and the PINs used by the staff to unlock them.
+type DeviceConfiguration struct {
+	// PinKey contains the secret used to hash the staff PINs. It needs to
+	// contain at least 32 characters. Changing the key invalidates every PIN
+	// that has already been set. The devices can only be unlocked if a key is
+	// set
+	PinKey *string `toml:"pinKey" secret:"true"`
+
+	// MaxPinAttempts contains the number of consecutive invalid PINs after
+	// which a device refuses further PINs for the PinLockout duration. If not
+	// set, five attempts are allowed
+	MaxPinAttempts *int `toml:"maxPinAttempts"`
+
+	// PinLockout contains the duration for which a device refuses PINs after
+	// too many invalid attempts as Go duration string. If not set, the device
+	// is blocked for five minutes
+	PinLockout *string `toml:"pinLockout"`
+
+	pinLockout time.Duration
+}
+
+// IsEnabled reports if the staff may set PINs and unlock the devices
+func (c *DeviceConfiguration) IsEnabled() bool {
+	return c.PinKey != nil
+}
+
+// Validate removes an empty key for the PIN hashes and checks if a configured
+// key is long enough. Afterwards, the defaults of the brute-force protection
+// are set
+func (c *DeviceConfiguration) Validate() (err error) {
+	if c.PinKey != nil && strings.TrimSpace(*c.PinKey) == "" {
+		c.PinKey = nil
+	}
+	if c.PinKey != nil && len(*c.PinKey) < 32 {
+		return ErrShortPinKey
+	}
+	if c.MaxPinAttempts == nil {
+		defaultAttempts := 5
+		c.MaxPinAttempts = &defaultAttempts
+	}
+	if *c.MaxPinAttempts < 1 {
+		return ErrInvalidMaxPinAttempts
+	}
+	c.pinLockout, err = parseOptionalDuration(c.PinLockout, 5*time.Minute)
+	if err != nil {
+		return errors.Join(ErrInvalidPinLockout, err)
+	}
+	if c.pinLockout <= 0 {
+		return ErrInvalidPinLockout
+	}
+	return nil
+}
+
+// Lockout returns the validated duration for which a device refuses PINs
+// after too many invalid attempts
+func (c *DeviceConfiguration) Lockout() time.Duration {
+	return c.pinLockout
+}
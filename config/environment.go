@@ -0,0 +1,248 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// EnvironmentPrefix is prepended to the names of the environment variables
+// which override the values of the configuration file
+const EnvironmentPrefix = "FILMMANAGEMENT"
+
+// FileSuffix is appended to the name of an environment variable to read the
+// value from the file at the path contained in the variable, e.g. a Docker
+// secret
+const FileSuffix = "_FILE"
+
+// maskedValue replaces the values of secret settings in the dump of the
+// configuration
+const maskedValue = "********"
+
+// ApplyEnvironment overrides the settings of the configuration with the
+// values of the environment variables. The name of the variable is built from
+// the path of the setting in the configuration file, converted to upper snake
+// case and prefixed with EnvironmentPrefix. Instead of the variable itself,
+// the variable with the FileSuffix may contain the path of a file containing
+// the value. Setting both variables is rejected.
+//
+// Strings, numbers and booleans are read as plain values, lists of strings
+// may be separated by commas. Every other setting, e.g. the role mapping or
+// the list of authorization servers, is read as TOML value.
+//
+// The environment needs to be applied after reading the configuration file,
+// since it overrides the values of the file. The default values are only set
+// afterward by the validation of the configuration and therefore only fill
+// the settings missing in both sources.
+//
+// Example:
+//
+//	FILMMANAGEMENT_DATABASE_HOST=postgres
+//	FILMMANAGEMENT_DATABASE_PASSWORD_FILE=/run/secrets/database-password
+//	FILMMANAGEMENT_LOCALIZATION_LANGUAGES=de,en
+//	FILMMANAGEMENT_OIDC_ROLES='{ admin = ["filmmanagement-admins"] }'
+func (c *Configuration) ApplyEnvironment() error {
+	return applyEnvironment(reflect.ValueOf(c).Elem(), EnvironmentPrefix)
+}
+
+// applyEnvironment overrides the exported fields of the struct with the
+// values of the environment variables using the supplied prefix. Nested
+// structs are applied recursively
+func applyEnvironment(value reflect.Value, prefix string) error {
+	valueType := value.Type()
+	for i := 0; i < valueType.NumField(); i++ {
+		field := valueType.Field(i)
+		key := tomlKey(field)
+		if !field.IsExported() || key == "" {
+			continue
+		}
+		name := prefix + "_" + environmentName(key)
+		if field.Type.Kind() == reflect.Struct {
+			if err := applyEnvironment(value.Field(i), name); err != nil {
+				return err
+			}
+			continue
+		}
+		raw, found, err := lookupEnvironment(name)
+		if err != nil {
+			return err
+		}
+		if !found {
+			continue
+		}
+		if err = setFromEnvironment(value.Field(i), raw); err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrInvalidEnvironmentVariable, name, err)
+		}
+	}
+	return nil
+}
+
+// lookupEnvironment returns the value of the environment variable or the
+// content of the file referenced by the variable with the FileSuffix
+func lookupEnvironment(name string) (string, bool, error) {
+	value, isSet := os.LookupEnv(name)
+	path, isFileSet := os.LookupEnv(name + FileSuffix)
+	if isSet && isFileSet {
+		return "", false, fmt.Errorf("%w: %s", ErrAmbiguousEnvironmentVariable, name)
+	}
+	if isSet {
+		return value, true, nil
+	}
+	if !isFileSet {
+		return "", false, nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", false, fmt.Errorf("%w: %s: %v", ErrInvalidEnvironmentVariable, name+FileSuffix, err)
+	}
+	// editors and "echo" usually append a line break to the file
+	return strings.TrimRight(string(content), "\r\n"), true, nil
+}
+
+// setFromEnvironment parses the raw value of an environment variable into
+// the field
+func setFromEnvironment(field reflect.Value, raw string) error {
+	if field.Kind() == reflect.Pointer {
+		value := reflect.New(field.Type().Elem())
+		if err := setFromEnvironment(value.Elem(), raw); err != nil {
+			return err
+		}
+		field.Set(value)
+		return nil
+	}
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Bool:
+		value, err := strconv.ParseBool(strings.TrimSpace(raw))
+		if err != nil {
+			return err
+		}
+		field.SetBool(value)
+	case reflect.Int, reflect.Int64:
+		value, err := strconv.ParseInt(strings.TrimSpace(raw), 10, 64)
+		if err != nil {
+			return err
+		}
+		field.SetInt(value)
+	case reflect.Float64:
+		value, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+		if err != nil {
+			return err
+		}
+		field.SetFloat(value)
+	case reflect.Slice:
+		if field.Type().Elem().Kind() == reflect.String && !strings.HasPrefix(strings.TrimSpace(raw), "[") {
+			values := reflect.MakeSlice(field.Type(), 0, 0)
+			for _, value := range strings.Split(raw, ",") {
+				if value = strings.TrimSpace(value); value != "" {
+					values = reflect.Append(values, reflect.ValueOf(value).Convert(field.Type().Elem()))
+				}
+			}
+			field.Set(values)
+			return nil
+		}
+		return setFromToml(field, raw)
+	default:
+		return setFromToml(field, raw)
+	}
+	return nil
+}
+
+// setFromToml parses the raw value as TOML value of the type of the field
+func setFromToml(field reflect.Value, raw string) error {
+	wrapperType := reflect.StructOf([]reflect.StructField{
+		{Name: "Value", Type: field.Type(), Tag: `toml:"value"`},
+	})
+	wrapper := reflect.New(wrapperType)
+	if err := toml.Unmarshal([]byte("value = "+raw), wrapper.Interface()); err != nil {
+		return err
+	}
+	field.Set(wrapper.Elem().Field(0))
+	return nil
+}
+
+// Masked returns the configuration as map using the keys of the
+// configuration file. The values of secret settings are replaced, therefore
+// the map may be written to the logs
+func (c *Configuration) Masked() map[string]interface{} {
+	return maskStruct(reflect.ValueOf(c).Elem())
+}
+
+// maskStruct converts the exported fields of the struct into a map and
+// replaces the values of the fields tagged as secret
+func maskStruct(value reflect.Value) map[string]interface{} {
+	masked := make(map[string]interface{})
+	valueType := value.Type()
+	for i := 0; i < valueType.NumField(); i++ {
+		field := valueType.Field(i)
+		key := tomlKey(field)
+		if !field.IsExported() || key == "" {
+			continue
+		}
+		fieldValue := value.Field(i)
+		if fieldValue.Kind() == reflect.Pointer {
+			if fieldValue.IsNil() {
+				continue
+			}
+			fieldValue = fieldValue.Elem()
+		}
+		if field.Tag.Get("secret") == "true" {
+			if !fieldValue.IsZero() {
+				masked[key] = maskedValue
+			}
+			continue
+		}
+		masked[key] = maskValue(fieldValue)
+	}
+	return masked
+}
+
+// maskValue converts structs and lists of structs into maps, every other
+// value is returned unchanged
+func maskValue(value reflect.Value) interface{} {
+	switch value.Kind() {
+	case reflect.Struct:
+		return maskStruct(value)
+	case reflect.Slice:
+		if value.Type().Elem().Kind() != reflect.Struct {
+			return value.Interface()
+		}
+		values := make([]interface{}, value.Len())
+		for i := range values {
+			values[i] = maskStruct(value.Index(i))
+		}
+		return values
+	default:
+		return value.Interface()
+	}
+}
+
+// tomlKey returns the key of the field in the configuration file
+func tomlKey(field reflect.StructField) string {
+	key, _, _ := strings.Cut(field.Tag.Get("toml"), ",")
+	if key == "-" {
+		return ""
+	}
+	return key
+}
+
+// environmentName converts the camel case key of the configuration file into
+// the upper snake case used by environment variables, e.g. "jwksUri" into
+// "JWKS_URI"
+func environmentName(key string) string {
+	var name strings.Builder
+	runes := []rune(key)
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1])) {
+			name.WriteRune('_')
+		}
+		name.WriteRune(unicode.ToUpper(r))
+	}
+	return name.String()
+}
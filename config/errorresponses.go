@@ -0,0 +1,16 @@
+package config
+
+// ErrorResponseConfiguration contains the configuration of the error
+// responses sent to the clients.
+type ErrorResponseConfiguration struct {
+	// ExposeDetails may be set to true during the development to include the
+	// cause of internal errors in the responses. Since the cause may contain
+	// details of the database, it is only logged by default
+	ExposeDetails *bool `toml:"exposeDetails"`
+}
+
+// ExposesDetails reports if the cause of internal errors is sent to the
+// clients
+func (c *ErrorResponseConfiguration) ExposesDetails() bool {
+	return c.ExposeDetails != nil && *c.ExposeDetails
+}
@@ -28,6 +28,31 @@ var ErrInvalidUserInfoURI = errors.New("invalid userinfo uri")
 // configuration is valid, but uses http
 var ErrInsecureUserInfoURI = errors.New("insecure userinfo uri")
 
+// ErrIncompleteTokenValidation is returned if only one of the issuer and the
+// key set address required for the local validation of access tokens is
+// known
+var ErrIncompleteTokenValidation = errors.New("issuer and jwks uri need to be set together")
+
+// ErrInvalidJwksURI is returned if the key set URI of the OpenIDConnect
+// configuration is not a valid URI
+var ErrInvalidJwksURI = errors.New("invalid jwks uri")
+
+// ErrInsecureJwksURI is returned if the key set URI of the OpenIDConnect
+// configuration is valid, but uses http
+var ErrInsecureJwksURI = errors.New("insecure jwks uri")
+
+// ErrInvalidUserInfoCacheTTL is returned if the configured duration for which
+// userinfo responses are cached is not a valid duration
+var ErrInvalidUserInfoCacheTTL = errors.New("invalid userinfo cache ttl")
+
+// ErrInvalidUserInfoCacheSize is returned if the configured size of the
+// userinfo cache is not positive
+var ErrInvalidUserInfoCacheSize = errors.New("invalid userinfo cache size")
+
+// ErrUnknownRole is returned if the OpenIDConnect configuration maps groups to
+// a role which is not known to the backend
+var ErrUnknownRole = errors.New("unknown role")
+
 // ErrNoDatabaseHost is returned if the configuration contains no host for the
 // MariaDB that is used in this project
 var ErrNoDatabaseHost = errors.New("database host not set")
@@ -51,3 +76,115 @@ var ErrNoDatabaseSpecified = errors.New("database name not set")
 // ErrEmptyDatabaseSpecified is returned if the configuration contains an empty
 // database name for the MariaDB that is used in this project
 var ErrEmptyDatabaseSpecified = errors.New("database name is empty")
+
+// ErrInvalidReservationHoldDuration is returned if the configured duration
+// before a screening at which reservations are released is not a valid,
+// positive duration
+var ErrInvalidReservationHoldDuration = errors.New("invalid reservation hold duration")
+
+// ErrInvalidReservationReleaseInterval is returned if the configured interval
+// of the reservation release job is not a valid, positive duration
+var ErrInvalidReservationReleaseInterval = errors.New("invalid reservation release interval")
+
+// ErrShortTicketSigningKey is returned if the configured key used to sign the
+// ticket tokens contains less than 32 characters
+var ErrShortTicketSigningKey = errors.New("ticket signing key is too short")
+
+// ErrInvalidTablePrefix is returned if the configured WordPress table prefix
+// contains characters which are not allowed in table names
+var ErrInvalidTablePrefix = errors.New("invalid wordpress table prefix")
+
+// ErrInvalidSyncInterval is returned if the configured interval of the
+// attendance synchronisation is not a valid, positive duration
+var ErrInvalidSyncInterval = errors.New("invalid attendance synchronisation interval")
+
+// ErrInvalidSyncAfter is returned if the configured duration after which
+// screenings are synchronised is not a valid, positive duration
+var ErrInvalidSyncAfter = errors.New("invalid attendance synchronisation delay")
+
+// ErrInvalidScreeningDuration is returned if the configured duration of a
+// screening in the calendar feed is not a valid, positive duration
+var ErrInvalidScreeningDuration = errors.New("invalid screening duration")
+
+// ErrInvalidCalendarHistory is returned if the configured duration for which
+// past screenings are kept in the calendar feed is not a valid duration
+var ErrInvalidCalendarHistory = errors.New("invalid calendar history")
+
+// ErrShortPinKey is returned if the configured key used to hash the staff
+// PINs contains less than 32 characters
+var ErrShortPinKey = errors.New("pin key is too short")
+
+// ErrInvalidMaxPinAttempts is returned if the number of allowed invalid PIN
+// attempts is not positive
+var ErrInvalidMaxPinAttempts = errors.New("invalid maximal number of pin attempts")
+
+// ErrInvalidPinLockout is returned if the lockout after too many invalid PIN
+// attempts is not a positive duration
+var ErrInvalidPinLockout = errors.New("invalid pin lockout duration")
+
+// ErrUnnamedOpenIdConnectProvider is returned if an entry of the list of
+// authorization servers has no name
+var ErrUnnamedOpenIdConnectProvider = errors.New("oidc provider without name")
+
+// ErrDuplicateOpenIdConnectProvider is returned if two authorization servers
+// share the same name or issuer
+var ErrDuplicateOpenIdConnectProvider = errors.New("duplicate oidc provider")
+
+// ErrAmbiguousTokenPrefix is returned if the token prefix of an authorization
+// server is empty or overlaps with the prefix of another server
+var ErrAmbiguousTokenPrefix = errors.New("ambiguous token prefix")
+
+// ErrMultipleOpaqueTokenFallbacks is returned if more than one authorization
+// server accepts the opaque tokens without a known prefix
+var ErrMultipleOpaqueTokenFallbacks = errors.New("multiple opaque token fallbacks")
+
+// ErrInvalidDevAuthAddress is returned if the address of the fake
+// authorization server is not a valid host and port
+var ErrInvalidDevAuthAddress = errors.New("invalid dev auth address")
+
+// ErrDevAuthNotLoopback is returned if the fake authorization server would
+// be reachable from other hosts
+var ErrDevAuthNotLoopback = errors.New("dev auth address is not a loopback address")
+
+// ErrInvalidDevAuthUser is returned if a test user of the development
+// authentication mode has no token or name, shares the token with another
+// user or the token does not start with DevAuthTokenPrefix
+var ErrInvalidDevAuthUser = errors.New("invalid dev auth user")
+
+// ErrInvalidLanguage is returned if a language of the error messages is not
+// a valid BCP 47 language tag
+var ErrInvalidLanguage = errors.New("invalid language")
+
+// ErrDuplicateLanguage is returned if a language of the error messages has
+// been configured multiple times
+var ErrDuplicateLanguage = errors.New("duplicate language")
+
+// ErrInvalidDrainTimeout is returned if the time the server waits for running
+// requests during the shutdown is not a positive duration
+var ErrInvalidDrainTimeout = errors.New("invalid drain timeout")
+
+// ErrInvalidHealthCheckTimeout is returned if the timeout of the dependency
+// checks is not a positive duration
+var ErrInvalidHealthCheckTimeout = errors.New("invalid health check timeout")
+
+// ErrInvalidHealthCheckCacheTTL is returned if the duration for which the
+// result of the readiness checks is reused is not a positive duration
+var ErrInvalidHealthCheckCacheTTL = errors.New("invalid health check cache ttl")
+
+// ErrUnsupportedTracingExporter is returned if the exporter of the spans is
+// neither "none", "otlp" nor "stdout"
+var ErrUnsupportedTracingExporter = errors.New("unsupported tracing exporter")
+
+// ErrInvalidSampleRatio is returned if the ratio of the recorded traces is not
+// between zero and one
+var ErrInvalidSampleRatio = errors.New("invalid trace sample ratio")
+
+// ErrInvalidEnvironmentVariable is returned if the value of an environment
+// variable overriding the configuration cannot be parsed or its file cannot
+// be read
+var ErrInvalidEnvironmentVariable = errors.New("invalid environment variable")
+
+// ErrAmbiguousEnvironmentVariable is returned if a setting is overridden by
+// an environment variable and by the variable referencing a file at the same
+// time
+var ErrAmbiguousEnvironmentVariable = errors.New("environment variable set together with its file variant")
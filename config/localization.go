@@ -0,0 +1,46 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+// LocalizationConfiguration contains the languages in which the error
+// messages of the API are available.
+type LocalizationConfiguration struct {
+	// Languages contains the languages every predefined error needs to be
+	// translated to as BCP 47 language tags. Every tag needs to be used as
+	// key of the translations in the "errors.json" file, e.g. "en" instead
+	// of "en-US" if the file only contains "en". The language of an error
+	// response is selected using the Accept-Language header of the request.
+	// The first language is used if the header does not match any of the
+	// languages. If not set, German and English are used
+	Languages []string `toml:"languages"`
+}
+
+// Validate sets the default languages if no languages have been configured
+// and checks that every configured language is a valid and unique language
+// tag. The tags are converted into their canonical form, e.g. "EN-us" into
+// "en-US", since the translations of the errors are looked up using the exact
+// tag
+func (c *LocalizationConfiguration) Validate() error {
+	if len(c.Languages) == 0 {
+		c.Languages = []string{"de", "en"}
+	}
+	configured := make(map[string]bool)
+	for i, lang := range c.Languages {
+		tag, err := language.Parse(strings.TrimSpace(lang))
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidLanguage, lang)
+		}
+		lang = tag.String()
+		if configured[lang] {
+			return fmt.Errorf("%w: %s", ErrDuplicateLanguage, lang)
+		}
+		configured[lang] = true
+		c.Languages[i] = lang
+	}
+	return nil
+}
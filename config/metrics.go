@@ -0,0 +1,26 @@
+package config
+
+import "strings"
+
+// MetricsConfiguration contains the configuration of the metrics endpoint
+// scraped by Prometheus.
+type MetricsConfiguration struct {
+	// Token contains the bearer token Prometheus needs to send to access the
+	// metrics. The metrics endpoint is only served if a token is set, since
+	// the metrics contain the revenue of the registers
+	Token *string `toml:"token" secret:"true"`
+}
+
+// IsEnabled reports if the metrics endpoint is served
+func (c *MetricsConfiguration) IsEnabled() bool {
+	return c.Token != nil
+}
+
+// Validate removes an empty token, which would otherwise allow requests
+// without a token
+func (c *MetricsConfiguration) Validate() error {
+	if c.Token != nil && strings.TrimSpace(*c.Token) == "" {
+		c.Token = nil
+	}
+	return nil
+}
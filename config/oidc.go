@@ -3,8 +3,12 @@ package config
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/url"
+	"time"
+
+	"digitales-filmmanagement-backend/types"
 )
 
 // OpenIdConnectConfiguration contains the configuration for the authentication
@@ -15,8 +19,19 @@ import (
 // When using the DiscoveryEndpoint, the function Validate takes the supplied
 // URI checks it and sets the UserInfoEndpoint. More information is documented
 // in the function itself.
+//
+// The backend accepts the tokens of multiple authorization servers. Each of
+// them is configured with its own OpenIdConnectConfiguration, see
+// Configuration.IdentityProviders.
 type OpenIdConnectConfiguration struct {
 
+	// Name identifies the authorization server in the logs. If it is set, the
+	// subjects of the users of the authorization server are prefixed with the
+	// name and a colon, which keeps them apart from the users of other
+	// authorization servers. The name is required for every entry of the
+	// "oidcProviders" list
+	Name *string `toml:"name"`
+
 	// DiscoveryEndpoint contains a URI pointing to an Open ID Connect Discovery
 	// 1.0 compliant endpoint of an authorization server
 	//
@@ -28,12 +43,79 @@ type OpenIdConnectConfiguration struct {
 	//
 	// See also: https://openid.net/specs/openid-connect-core-1_0.html#UserInfo
 	UserInfoEndpoint *string `toml:"userInfoEndpoint"`
+
+	// Issuer contains the issuer identifier of the authorization server. It
+	// is compared to the "iss" claim of locally validated access tokens. If
+	// not set, it is taken from the discovery response
+	Issuer *string `toml:"issuer"`
+
+	// JwksURI contains a URI pointing to the JSON Web Key Set of the
+	// authorization server, which is used to validate JWT access tokens
+	// locally. If not set, it is taken from the discovery response. If neither
+	// is available, every token is validated using the userinfo endpoint
+	JwksURI *string `toml:"jwksUri"`
+
+	// Audience contains the value expected in the "aud" claim of locally
+	// validated access tokens, usually the client id of the frontend. If not
+	// set, the audience is not checked
+	Audience *string `toml:"audience"`
+
+	// GroupsClaim contains the name of the claim in the userinfo response
+	// which lists the groups of a user. If not set, "groups" is used
+	GroupsClaim *string `toml:"groupsClaim"`
+
+	// TokenPrefix contains the prefix of the opaque access tokens issued by
+	// the authorization server. Opaque tokens are only sent to the userinfo
+	// endpoint of the server whose prefix they start with
+	TokenPrefix *string `toml:"tokenPrefix"`
+
+	// OpaqueTokenFallback allows sending the opaque tokens which do not start
+	// with the prefix of any authorization server to the userinfo endpoint of
+	// this server. It may only be enabled for a single server. If only one
+	// authorization server is configured, it receives these tokens anyway
+	OpaqueTokenFallback *bool `toml:"opaqueTokenFallback"`
+
+	// NameClaim contains the name of the claim which is used as display name
+	// of a user. If not set, the first non-empty claim of "name",
+	// "given_name", "preferred_username" and "email" is used
+	NameClaim *string `toml:"nameClaim"`
+
+	// Roles maps the backend roles to the groups whose members are granted the
+	// role. A user may be granted multiple roles. Users without any role are
+	// not allowed to access the API
+	//
+	// Example:
+	//
+	//	[oidc.roles]
+	//	admin = ["filmmanagement-admins"]
+	//	treasurer = ["board"]
+	//	bar = ["volunteers"]
+	Roles map[types.Role][]string `toml:"roles"`
+
+	// UserInfoCacheTTL contains the duration for which userinfo responses are
+	// cached as Go duration string. Cached responses are never used after the
+	// expiry of the token, if the expiry is known. Since a cached token is
+	// not checked by the authorization server again, a revoked token is
+	// accepted for up to this duration. If not set, responses are cached for
+	// one minute. Setting the value to "0s" disables the cache
+	UserInfoCacheTTL *string `toml:"userInfoCacheTtl"`
+
+	// UserInfoCacheSize contains the maximal number of cached userinfo
+	// responses. If the cache is full, the least recently used response is
+	// removed. If not set, 1000 responses are cached
+	UserInfoCacheSize *int `toml:"userInfoCacheSize"`
+
+	userInfoCacheTTL time.Duration
+	// allowInsecure allows plain http endpoints. It is only set for the fake
+	// authorization server of the development authentication mode
+	allowInsecure bool
 }
 
 // Validate checks if either the user info endpoint was set in the open id
-// connect configuration. If not, it checks if the discovery endpoint was
-// set and requests its information and builds the user information endpoint
-// address from the response.
+// connect configuration. If the discovery endpoint was set, it requests its
+// information and takes the user information endpoint, the issuer and the
+// key set address from the response unless they have been configured
+// manually.
 //
 // If something fails or otherwise does not work, an error will be returned
 func (c *OpenIdConnectConfiguration) Validate() error {
@@ -41,11 +123,9 @@ func (c *OpenIdConnectConfiguration) Validate() error {
 	if c.DiscoveryEndpoint == nil && c.UserInfoEndpoint == nil {
 		return ErrEmptyOpenIdConnectConfig
 	}
-	// now check if the user info endpoint string contains a valid url
-	if c.UserInfoEndpoint == nil {
-		// since the user info endpoint was not set, use the discovery endpoint
-		// to get the userinfo endpoint.
-
+	// now use the discovery endpoint to get the addresses which have not been
+	// configured manually
+	if c.DiscoveryEndpoint != nil {
 		// check if the provided discovery endpoint is a valid uri
 		uri, err := url.Parse(*c.DiscoveryEndpoint)
 		if err != nil {
@@ -63,6 +143,7 @@ func (c *OpenIdConnectConfiguration) Validate() error {
 		if err != nil {
 			return err
 		}
+		defer rawDiscoveryResponse.Body.Close()
 
 		// now parse the request into a map
 		discoveryResponse := make(map[string]interface{})
@@ -72,15 +153,78 @@ func (c *OpenIdConnectConfiguration) Validate() error {
 		}
 
 		// now check if the response contains a value for the userinfo endpoint
-		userInfoEndpoint, isSet := discoveryResponse["userinfo_endpoint"]
-		if !isSet {
-			return ErrDiscoveryResponseMissingUserInfo
+		if c.UserInfoEndpoint == nil {
+			userInfoEndpoint, isSet := discoveryResponse["userinfo_endpoint"]
+			if !isSet {
+				return ErrDiscoveryResponseMissingUserInfo
+			}
+			userInfoEndpointUri, isString := userInfoEndpoint.(string)
+			if !isString {
+				return ErrInvalidDiscoveryResponse
+			}
+			c.UserInfoEndpoint = &userInfoEndpointUri
+		}
+
+		// now take the issuer and the key set used for the local validation
+		// of access tokens. both values are optional in the response, since
+		// the userinfo endpoint can still be used without them
+		issuer, issuerIsString := discoveryResponse["issuer"].(string)
+		jwksUri, jwksUriIsString := discoveryResponse["jwks_uri"].(string)
+		if issuerIsString && jwksUriIsString {
+			if c.Issuer == nil {
+				c.Issuer = &issuer
+			}
+			if c.JwksURI == nil {
+				c.JwksURI = &jwksUri
+			}
+		}
+	}
+
+	// the local validation needs both the issuer and the key set, therefore
+	// setting only one of them is an error
+	if (c.Issuer == nil) != (c.JwksURI == nil) {
+		return ErrIncompleteTokenValidation
+	}
+	if c.JwksURI != nil {
+		jwksUrl, err := url.Parse(*c.JwksURI)
+		if err != nil {
+			return errors.Join(ErrInvalidJwksURI, err)
 		}
-		userInfoEndpointUri, isString := userInfoEndpoint.(string)
-		if !isString {
-			return ErrInvalidDiscoveryResponse
+		if jwksUrl.Scheme != "https" && !c.allowInsecure {
+			return ErrInsecureJwksURI
+		}
+	}
+
+	// now check if the optional groups claim was set. if not, set it to the
+	// commonly used claim name
+	if c.GroupsClaim == nil {
+		defaultClaim := "groups"
+		c.GroupsClaim = &defaultClaim
+	}
+
+	// now set up the userinfo cache
+	var err error
+	c.userInfoCacheTTL, err = parseOptionalDuration(c.UserInfoCacheTTL, time.Minute)
+	if err != nil || c.userInfoCacheTTL < 0 {
+		return errors.Join(ErrInvalidUserInfoCacheTTL, err)
+	}
+	if c.UserInfoCacheSize == nil {
+		defaultSize := 1000
+		c.UserInfoCacheSize = &defaultSize
+	}
+	if *c.UserInfoCacheSize < 1 {
+		return ErrInvalidUserInfoCacheSize
+	}
+
+	if c.TokenPrefix != nil && *c.TokenPrefix == "" {
+		return ErrAmbiguousTokenPrefix
+	}
+
+	// now check that only known roles are mapped to groups
+	for role := range c.Roles {
+		if !role.IsKnown() {
+			return fmt.Errorf("%w: %s", ErrUnknownRole, role)
 		}
-		c.UserInfoEndpoint = &userInfoEndpointUri
 	}
 
 	endpointUrl, err := url.Parse(*c.UserInfoEndpoint)
@@ -89,9 +233,47 @@ func (c *OpenIdConnectConfiguration) Validate() error {
 	}
 	// now check that the scheme is https since the specification requires the
 	// usage of https
-	if endpointUrl.Scheme != "https" {
+	if endpointUrl.Scheme != "https" && !c.allowInsecure {
 		return ErrInsecureUserInfoURI
 	}
 
 	return nil
 }
+
+// IsConfigured reports if any endpoint of the authorization server has been
+// configured
+func (c *OpenIdConnectConfiguration) IsConfigured() bool {
+	return c.DiscoveryEndpoint != nil || c.UserInfoEndpoint != nil
+}
+
+// Subject returns the identifier used by the backend for the user with the
+// supplied subject at this authorization server
+func (c *OpenIdConnectConfiguration) Subject(subject string) string {
+	if c.Name == nil {
+		return subject
+	}
+	return *c.Name + ":" + subject
+}
+
+// IsOpaqueTokenFallback reports if the authorization server accepts the
+// opaque tokens which do not start with the prefix of any server
+func (c *OpenIdConnectConfiguration) IsOpaqueTokenFallback() bool {
+	return c.OpaqueTokenFallback != nil && *c.OpaqueTokenFallback
+}
+
+// DisplayName returns the name of the authorization server used in the logs
+func (c *OpenIdConnectConfiguration) DisplayName() string {
+	if c.Name != nil {
+		return *c.Name
+	}
+	if c.Issuer != nil {
+		return *c.Issuer
+	}
+	return *c.UserInfoEndpoint
+}
+
+// CacheTTL returns the validated duration for which userinfo responses are
+// cached
+func (c *OpenIdConnectConfiguration) CacheTTL() time.Duration {
+	return c.userInfoCacheTTL
+}
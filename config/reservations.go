@@ -0,0 +1,66 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// ReservationConfiguration contains the configuration for the online seat
+// reservations. Both durations are written as Go duration strings
+// (e.g. "30m") and are optional.
+type ReservationConfiguration struct {
+	// HoldUntilBeforeStart contains the duration before the start of a
+	// screening at which unclaimed reservations are released. If not set,
+	// reservations are held until 30 minutes before the screening
+	HoldUntilBeforeStart *string `toml:"holdUntilBeforeStart"`
+
+	// ReleaseInterval contains the interval in which the background job
+	// releasing unclaimed reservations runs. If not set, the job runs every
+	// minute
+	ReleaseInterval *string `toml:"releaseInterval"`
+
+	holdDuration    time.Duration
+	releaseInterval time.Duration
+}
+
+// Validate parses the configured durations and sets the default values for
+// every duration that has not been configured
+func (c *ReservationConfiguration) Validate() (err error) {
+	c.holdDuration, err = parseOptionalDuration(c.HoldUntilBeforeStart, 30*time.Minute)
+	if err != nil {
+		return errors.Join(ErrInvalidReservationHoldDuration, err)
+	}
+	if c.holdDuration < 0 {
+		return ErrInvalidReservationHoldDuration
+	}
+	c.releaseInterval, err = parseOptionalDuration(c.ReleaseInterval, time.Minute)
+	if err != nil {
+		return errors.Join(ErrInvalidReservationReleaseInterval, err)
+	}
+	if c.releaseInterval <= 0 {
+		return ErrInvalidReservationReleaseInterval
+	}
+	return nil
+}
+
+// HoldDuration returns the validated duration before the start of a screening
+// at which unclaimed reservations are released
+func (c *ReservationConfiguration) HoldDuration() time.Duration {
+	return c.holdDuration
+}
+
+// Interval returns the validated interval of the background job releasing
+// unclaimed reservations
+func (c *ReservationConfiguration) Interval() time.Duration {
+	return c.releaseInterval
+}
+
+// parseOptionalDuration parses the supplied duration string and returns the
+// default value if the string has not been set or is empty
+func parseOptionalDuration(value *string, defaultValue time.Duration) (time.Duration, error) {
+	if value == nil || strings.TrimSpace(*value) == "" {
+		return defaultValue, nil
+	}
+	return time.ParseDuration(strings.TrimSpace(*value))
+}
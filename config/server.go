@@ -0,0 +1,68 @@
+package config
+
+import (
+	"errors"
+	"time"
+)
+
+// ServerConfiguration contains the configuration of the http server.
+type ServerConfiguration struct {
+	// DrainTimeout contains the duration for which the server waits for
+	// running requests to finish after receiving a shutdown signal as Go
+	// duration string. Requests still running afterward are cancelled. The
+	// timeout should be shorter than the stop timeout of the container
+	// runtime. If not set, the server waits up to 25 seconds
+	DrainTimeout *string `toml:"drainTimeout"`
+
+	// HealthCheckTimeout contains the duration after which the check of a
+	// single dependency by the readiness endpoint fails as Go duration
+	// string. If not set, every check may take up to two seconds
+	HealthCheckTimeout *string `toml:"healthCheckTimeout"`
+
+	// HealthCheckCacheTTL contains the duration for which the result of the
+	// readiness checks is reused as Go duration string. Since the readiness
+	// endpoint is available without authentication, the cache limits the
+	// load it causes on the dependencies. If not set, the result is reused
+	// for five seconds
+	HealthCheckCacheTTL *string `toml:"healthCheckCacheTtl"`
+
+	drainTimeout        time.Duration
+	healthCheckTimeout  time.Duration
+	healthCheckCacheTTL time.Duration
+}
+
+// Validate parses the configured durations and sets the default values for
+// every setting that has not been configured
+func (c *ServerConfiguration) Validate() (err error) {
+	c.drainTimeout, err = parseOptionalDuration(c.DrainTimeout, 25*time.Second)
+	if err != nil || c.drainTimeout <= 0 {
+		return errors.Join(ErrInvalidDrainTimeout, err)
+	}
+	c.healthCheckTimeout, err = parseOptionalDuration(c.HealthCheckTimeout, 2*time.Second)
+	if err != nil || c.healthCheckTimeout <= 0 {
+		return errors.Join(ErrInvalidHealthCheckTimeout, err)
+	}
+	c.healthCheckCacheTTL, err = parseOptionalDuration(c.HealthCheckCacheTTL, 5*time.Second)
+	if err != nil || c.healthCheckCacheTTL <= 0 {
+		return errors.Join(ErrInvalidHealthCheckCacheTTL, err)
+	}
+	return nil
+}
+
+// Drain returns the validated duration for which the server waits for
+// running requests during the shutdown
+func (c *ServerConfiguration) Drain() time.Duration {
+	return c.drainTimeout
+}
+
+// HealthCheck returns the validated duration after which the check of a
+// single dependency fails
+func (c *ServerConfiguration) HealthCheck() time.Duration {
+	return c.healthCheckTimeout
+}
+
+// HealthCheckCache returns the validated duration for which the result of the
+// readiness checks is reused
+func (c *ServerConfiguration) HealthCheckCache() time.Duration {
+	return c.healthCheckCacheTTL
+}
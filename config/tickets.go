@@ -0,0 +1,30 @@
+package config
+
+import "strings"
+
+// TicketConfiguration contains the configuration for the signed tokens
+// printed as QR codes on tickets and reservations.
+type TicketConfiguration struct {
+	// SigningKey contains the secret used to sign the ticket tokens. It needs
+	// to contain at least 32 characters. Changing the key invalidates every
+	// token that has already been issued. The QR codes and the check-in are
+	// only available if a key is set
+	SigningKey *string `toml:"signingKey" secret:"true"`
+}
+
+// IsEnabled reports if the QR codes and the check-in are available
+func (c *TicketConfiguration) IsEnabled() bool {
+	return c.SigningKey != nil
+}
+
+// Validate removes an empty signing key and checks if a configured key is
+// long enough to securely sign the ticket tokens
+func (c *TicketConfiguration) Validate() error {
+	if c.SigningKey != nil && strings.TrimSpace(*c.SigningKey) == "" {
+		c.SigningKey = nil
+	}
+	if c.SigningKey != nil && len(*c.SigningKey) < 32 {
+		return ErrShortTicketSigningKey
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package config
+
+import "strings"
+
+// TracingExporterNone, TracingExporterOTLP and TracingExporterStdout are the
+// supported exporters of the recorded spans
+const (
+	TracingExporterNone   = "none"
+	TracingExporterOTLP   = "otlp"
+	TracingExporterStdout = "stdout"
+)
+
+// TracingConfiguration contains the configuration of the OpenTelemetry
+// tracing of the requests, the database queries and the requests to the
+// authorization servers.
+type TracingConfiguration struct {
+	// Exporter selects where the spans are sent to. "otlp" sends them to an
+	// OpenTelemetry collector using OTLP over http, "stdout" prints them for
+	// local runs. If not set, "none" is used and no spans are recorded
+	Exporter *string `toml:"exporter"`
+
+	// Endpoint contains the host and port of the OpenTelemetry collector
+	// receiving the spans via OTLP. If not set, the OTEL_EXPORTER_OTLP_*
+	// environment variables of the OpenTelemetry SDK are used, which default
+	// to "localhost:4318"
+	Endpoint *string `toml:"endpoint"`
+
+	// Insecure disables the transport security for the connection to the
+	// OpenTelemetry collector
+	Insecure bool `toml:"insecure"`
+
+	// ServiceName contains the name of the service attached to every span. If
+	// not set, "filmmanagement-backend" is used
+	ServiceName *string `toml:"serviceName"`
+
+	// SampleRatio contains the ratio of the traces which are recorded. Traces
+	// started by other services keep their sampling decision. If not set,
+	// every trace is recorded
+	SampleRatio *float64 `toml:"sampleRatio"`
+}
+
+// Validate sets the default values and checks that a supported exporter has
+// been selected
+func (c *TracingConfiguration) Validate() error {
+	setDefault(&c.Exporter, TracingExporterNone)
+	setDefault(&c.ServiceName, "filmmanagement-backend")
+	*c.Exporter = strings.ToLower(strings.TrimSpace(*c.Exporter))
+	switch *c.Exporter {
+	case TracingExporterNone, TracingExporterOTLP, TracingExporterStdout:
+	default:
+		return ErrUnsupportedTracingExporter
+	}
+	if c.SampleRatio == nil {
+		defaultRatio := 1.0
+		c.SampleRatio = &defaultRatio
+	}
+	if *c.SampleRatio < 0 || *c.SampleRatio > 1 {
+		return ErrInvalidSampleRatio
+	}
+	return nil
+}
+
+// IsEnabled reports if spans are recorded and exported
+func (c *TracingConfiguration) IsEnabled() bool {
+	return c.Exporter != nil && *c.Exporter != TracingExporterNone
+}
@@ -1,20 +1,73 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"regexp"
 	"strings"
+	"time"
 )
 
+// tablePrefixPattern matches the table prefixes which may be used in the
+// queries against the WordPress database
+var tablePrefixPattern = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
 // WpDbConfiguration contains the configuration for the database connection
 // to the MariaDB server required for this backend to work. It contains an
 // internal boolean to check if it has been validated.
 type WpDbConfiguration struct {
-	Host      *string `toml:"host"`
-	Port      *string `toml:"port"`
-	User      *string `toml:"user"`
-	Password  *string `toml:"password"`
-	Schema    *string `toml:"schema"`
-	validated bool
+	Host     *string `toml:"host"`
+	Port     *string `toml:"port"`
+	User     *string `toml:"user"`
+	Password *string `toml:"password" secret:"true"`
+	Schema   *string `toml:"schema"`
+
+	// TablePrefix contains the prefix of the WordPress tables. If not set,
+	// the WordPress default "wp_" is used
+	TablePrefix *string `toml:"tablePrefix"`
+
+	// SyncAttendance enables the background job writing the attendance of
+	// finished screenings into the meta data of their WordPress posts
+	SyncAttendance bool `toml:"syncAttendance"`
+
+	// AttendanceMetaKey contains the post meta key the attendance is written
+	// to. If not set, "attendance" is used
+	AttendanceMetaKey *string `toml:"attendanceMetaKey"`
+
+	// RevenueMetaKey contains the post meta key the ticket revenue is written
+	// to. The revenue is only written if a key has been configured
+	RevenueMetaKey *string `toml:"revenueMetaKey"`
+
+	// SyncInterval contains the interval in which the attendance is
+	// synchronised as Go duration string. If not set, the job runs every 15
+	// minutes
+	SyncInterval *string `toml:"syncInterval"`
+
+	// SyncAfter contains the duration after the start of a screening after
+	// which the screening is considered finished. If not set, screenings are
+	// synchronised three hours after their start
+	SyncAfter *string `toml:"syncAfter"`
+
+	// ScreeningPostType contains the post type of the posts describing the
+	// screenings. If not set, "screening" is used
+	ScreeningPostType *string `toml:"screeningPostType"`
+
+	// StartMetaKey contains the post meta key holding the start of a
+	// screening either as unix timestamp or as local time in the format
+	// "2006-01-02 15:04". If not set, "start" is used
+	StartMetaKey *string `toml:"startMetaKey"`
+
+	// VenueMetaKey contains the post meta key holding the name of the venue
+	// of a screening. If not set, "venue" is used
+	VenueMetaKey *string `toml:"venueMetaKey"`
+
+	// CategoryTaxonomy contains the taxonomy used to categorise the
+	// screenings. If not set, "category" is used
+	CategoryTaxonomy *string `toml:"categoryTaxonomy"`
+
+	syncInterval time.Duration
+	syncAfter    time.Duration
+	validated    bool
 }
 
 // Validate checks if the configuration contains at least the user, host and
@@ -70,6 +123,37 @@ func (c *WpDbConfiguration) Validate() error {
 		c.Password = &defaultPassword
 	}
 
+	// now check the optional table prefix. since it is used to build the
+	// queries, it may only contain characters which are safe to use in
+	// table names
+	if c.TablePrefix == nil || strings.TrimSpace(*c.TablePrefix) == "" {
+		defaultPrefix := "wp_"
+		c.TablePrefix = &defaultPrefix
+	}
+	if !tablePrefixPattern.MatchString(*c.TablePrefix) {
+		return ErrInvalidTablePrefix
+	}
+
+	// now set the default meta key for the attendance
+	setDefault(&c.AttendanceMetaKey, "attendance")
+
+	// now set the defaults describing the layout of the screening posts
+	setDefault(&c.ScreeningPostType, "screening")
+	setDefault(&c.StartMetaKey, "start")
+	setDefault(&c.VenueMetaKey, "venue")
+	setDefault(&c.CategoryTaxonomy, "category")
+
+	// now parse the durations used by the synchronisation
+	var err error
+	c.syncInterval, err = parseOptionalDuration(c.SyncInterval, 15*time.Minute)
+	if err != nil || c.syncInterval <= 0 {
+		return errors.Join(ErrInvalidSyncInterval, err)
+	}
+	c.syncAfter, err = parseOptionalDuration(c.SyncAfter, 3*time.Hour)
+	if err != nil || c.syncAfter < 0 {
+		return errors.Join(ErrInvalidSyncAfter, err)
+	}
+
 	// since no errors occurred, return nil to indicate that no error occurred
 	// and set the validation indicator to true
 	c.validated = true
@@ -77,6 +161,22 @@ func (c *WpDbConfiguration) Validate() error {
 
 }
 
+// Table returns the name of the WordPress table with the configured prefix
+func (c *WpDbConfiguration) Table(name string) string {
+	return *c.TablePrefix + name
+}
+
+// Interval returns the validated interval of the attendance synchronisation
+func (c *WpDbConfiguration) Interval() time.Duration {
+	return c.syncInterval
+}
+
+// FinishedAfter returns the validated duration after the start of a screening
+// after which its attendance is synchronised
+func (c *WpDbConfiguration) FinishedAfter() time.Duration {
+	return c.syncAfter
+}
+
 // BuildDSN returns a connection string for sql.Open. Before
 // building the connection string, the configuration needs to be validated with
 // Validate. If the configuration is not validated, an empty string will be
@@ -89,3 +189,11 @@ func (c *WpDbConfiguration) BuildDSN() string {
 	return ""
 
 }
+
+// setDefault sets the value of an optional string setting to the supplied
+// default value if the setting has not been configured or is empty
+func setDefault(setting **string, defaultValue string) {
+	if *setting == nil || strings.TrimSpace(**setting) == "" {
+		*setting = &defaultValue
+	}
+}
@@ -0,0 +1,73 @@
+package devauth
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"digitales-filmmanagement-backend/config"
+)
+
+// Start starts the fake authorization server of the development
+// authentication mode. The server answers discovery and userinfo requests for
+// the configured test users. It returns once the server listens on the
+// configured address, so the backend can validate tokens right away.
+//
+// The server does not issue tokens and does not publish a key set. The test
+// users authenticate with the static tokens of the configuration, which are
+// always validated using the userinfo endpoint. Therefore, the local
+// validation of JWT access tokens is never used in this mode and needs to be
+// tested against a real authorization server. The tokens of the test users
+// are only logged at the debug level
+func Start(c *config.DevAuthConfiguration) (*http.Server, error) {
+	users := make(map[string]config.DevAuthUser, len(c.Users))
+	for _, user := range c.Users {
+		users[user.Token] = user
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(map[string]string{
+			"issuer":            c.Issuer(),
+			"userinfo_endpoint": c.Issuer() + "/userinfo",
+		})
+		if err != nil {
+			log.Error().Err(err).Msg("unable to send dev auth discovery document")
+		}
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		scheme, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+		user, known := users[strings.TrimSpace(token)]
+		if !strings.EqualFold(scheme, "bearer") || !known {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(user); err != nil {
+			log.Error().Err(err).Msg("unable to send dev auth userinfo")
+		}
+	})
+
+	listener, err := net.Listen("tcp", *c.Address)
+	if err != nil {
+		return nil, err
+	}
+	server := &http.Server{Handler: mux}
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Error().Err(err).Msg("the dev auth server stopped unexpectedly")
+		}
+	}()
+
+	log.Warn().Str("issuer", c.Issuer()).Int("users", len(users)).
+		Msg("development authentication enabled. never use this mode in production")
+	for _, user := range c.Users {
+		log.Info().Str("name", user.Name).Interface("roles", user.Roles).Msg("dev auth test user")
+		log.Debug().Str("name", user.Name).Str("token", user.Token).Msg("dev auth test user token")
+	}
+	return server, nil
+}
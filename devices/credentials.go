@@ -0,0 +1,56 @@
+package devices
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+
+	"digitales-filmmanagement-backend/globals"
+)
+
+// KeyPrefix is prepended to every device key. It allows the authentication
+// middleware to tell device keys and OpenID Connect access tokens apart
+const KeyPrefix = "dev_"
+
+// keyLength contains the number of random bytes in a device key
+const keyLength = 32
+
+// GenerateKey creates a new random device key. The key is only shown once
+// when the device is registered, the database only stores its hash
+func GenerateKey() (string, error) {
+	key := make([]byte, keyLength)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return KeyPrefix + base64.RawURLEncoding.EncodeToString(key), nil
+}
+
+// IsKey reports if the supplied token is a device key
+func IsKey(token string) bool {
+	return strings.HasPrefix(token, KeyPrefix)
+}
+
+// HashKey returns the hash of a device key which is stored in the database.
+// Since the keys are long random values, a plain SHA-256 hash is sufficient
+func HashKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
+
+// HashPin returns the hash of a staff PIN which is stored in the database.
+// Since PINs are short, they are hashed with a HMAC using the configured
+// secret key, which prevents guessing the PINs from a leaked database
+func HashPin(pin string) string {
+	mac := hmac.New(sha256.New, []byte(*globals.Configuration.Devices.PinKey))
+	mac.Write([]byte(pin))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// PinMatches reports if the PIN matches the stored hash. The hashes are
+// compared in constant time
+func PinMatches(pinHash string, pin string) bool {
+	return hmac.Equal([]byte(pinHash), []byte(HashPin(pin)))
+}
@@ -5,15 +5,12 @@ import (
 	"digitales-filmmanagement-backend/types"
 	"net/http"
 
-	"github.com/go-chi/httplog"
-	"github.com/qustavo/dotsql"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 
 	"digitales-filmmanagement-backend/config"
+	"digitales-filmmanagement-backend/tracing"
 )
 
-// HttpLogger is the logger used by the code interacting with API requests
-var HttpLogger = httplog.NewLogger("management-backend", httplog.Options{JSON: true})
-
 // Configuration is the main configuration for this application. It contains
 // every used subsection from the 'configuration.toml' file.
 var Configuration config.Configuration
@@ -24,11 +21,13 @@ var Database *sql.DB
 // WpDatabase is the shared connection to the MariaDB Database used by the backend
 var WpDatabase *sql.DB
 
-// SqlQueries contains the loaded sql queries from `queries.sql`
-var SqlQueries *dotsql.DotSql
+// SqlQueries contains the loaded sql queries from `queries.sql`. Their
+// executions are recorded as spans if they are executed with a context
+var SqlQueries tracing.Queries
 
-// HttpClient is a globally usable http client with no additional configuration
-var HttpClient = &http.Client{}
+// HttpClient is a globally usable http client which records its requests as
+// spans and passes the trace context on to the requested servers
+var HttpClient = &http.Client{Transport: otelhttp.NewTransport(http.DefaultTransport)}
 
 // Errors contains all predefined types.APIError entries as an mapping between
 // the error code and the error object
@@ -4,7 +4,10 @@ import (
 	"database/sql"
 	"digitales-filmmanagement-backend/types"
 	"encoding/json"
+	"errors"
+	"io/fs"
 	"os"
+	"regexp"
 	"time"
 
 	"github.com/pelletier/go-toml/v2"
@@ -15,15 +18,19 @@ import (
 
 	"digitales-filmmanagement-backend/config"
 	"digitales-filmmanagement-backend/globals"
+	"digitales-filmmanagement-backend/tracing"
 	// database driver
 	_ "github.com/go-sql-driver/mysql"
 	_ "github.com/lib/pq"
 )
 
+// queryNamePattern matches the name tags of the queries in a sql file loaded
+// by dotsql
+var queryNamePattern = regexp.MustCompile(`(?m)^\s*--\s*name:\s*(\S+)`)
+
 // This function configures the zerolog logging library which is used for
-// logging during the initialization and the server start-up. The http
-// handlers use another logging library which is more integrated with the
-// server.
+// logging during the initialization, the server start-up and the handling of
+// the requests, including the access log.
 func init() {
 	// set up the time format used in the logging outputs
 	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
@@ -43,16 +50,16 @@ func init() {
 			log.Warn().Str("readLevel", level).Msg("invalid level supplied in `LOG_LEVEL`. defaulting to 'info'")
 		}
 		zerolog.SetGlobalLevel(zerolog.InfoLevel)
-		globals.HttpLogger = globals.HttpLogger.Level(zerolog.InfoLevel)
 	} else {
 		log.Info().Str("readLevel", level).Msg("configuring zerolog with desired logging level")
 		zerolog.SetGlobalLevel(parsedLevel)
-		globals.HttpLogger = globals.HttpLogger.Level(parsedLevel)
 	}
 }
 
 // This function reads the configuration file used to set up the database
-// connection and the authorization validation
+// connection and the authorization validation. Every setting of the file may
+// be overridden using environment variables, see
+// config.Configuration.ApplyEnvironment
 func init() {
 	// read the environment and check for the variable `CONFIGURATION_LOCATION`
 	fileLocation, isSet := os.LookupEnv("CONFIGURATION_LOCATION")
@@ -64,36 +71,113 @@ func init() {
 
 	// now create an empty configuration object
 	var conf config.Configuration
-	// now try to open the configuration file
+	// now try to open the configuration file. the default file is optional,
+	// since the configuration may be supplied using environment variables
 	file, err := os.Open(fileLocation)
-	if err != nil {
+	switch {
+	case err == nil:
+		// now try to read it
+		err = toml.NewDecoder(file).Decode(&conf)
+		if err != nil {
+			log.Fatal().Err(err).Msg("unable to read/parse configuration file")
+		}
+		_ = file.Close()
+	case !isSet && errors.Is(err, fs.ErrNotExist):
+		log.Info().Msg("no configuration file found. using environment variables only")
+	default:
 		log.Fatal().Err(err).Msg("unable to open configuration file")
 	}
-	// and now try to read it
-	err = toml.NewDecoder(file).Decode(&conf)
+	// now apply the settings from the environment, which take precedence over
+	// the configuration file
+	err = conf.ApplyEnvironment()
 	if err != nil {
-		log.Fatal().Err(err).Msg("unable to read/parse configuration file")
+		log.Fatal().Err(err).Msg("unable to apply the configuration from the environment")
 	}
-	// now log the configuration to the debug output
-	log.Debug().Interface("config", conf).Send()
 	// after reading the configuration, validate the sub-configurations
-	err = conf.OIDC.Validate()
+	err = conf.ValidateIdentityProviders()
 	if err != nil {
 		log.Fatal().Err(err).Msg("invalid OIDC configuration")
 	}
-	// since this step modifies some values, reprint the configuration
-	log.Debug().Interface("config", conf).Msg("configuration modified")
+	if providers := conf.IdentityProviders(); len(providers) > 1 {
+		hasFallback := false
+		for _, provider := range providers {
+			hasFallback = hasFallback || provider.IsOpaqueTokenFallback()
+		}
+		if !hasFallback {
+			log.Warn().Msg("no opaque token fallback configured. opaque tokens without a known token prefix are refused")
+		}
+	}
+	// the development authentication mode is never enabled implicitly, even
+	// if test users have been configured
+	if !conf.DevAuth.IsEnabled() && len(conf.DevAuth.Users) > 0 {
+		log.Warn().Msg("dev auth users configured, but the dev auth mode is not enabled. ignoring the users")
+	}
 	err = conf.Database.Validate()
 	if err != nil {
 		log.Fatal().Err(err).Msg("invalid database configuration")
 	}
+	// the validation sets the default values used by the attendance
+	// synchronisation
+	err = conf.WordPress.Validate()
+	if err != nil {
+		log.Fatal().Err(err).Msg("invalid wordpress configuration")
+	}
+	err = conf.Reservations.Validate()
+	if err != nil {
+		log.Fatal().Err(err).Msg("invalid reservation configuration")
+	}
+	err = conf.Tickets.Validate()
+	if err != nil {
+		log.Fatal().Err(err).Msg("invalid ticket configuration")
+	}
+	if !conf.Tickets.IsEnabled() {
+		log.Warn().Msg("no ticket signing key configured. the qr codes and the check-in are disabled")
+	}
+	err = conf.Calendar.Validate()
+	if err != nil {
+		log.Fatal().Err(err).Msg("invalid calendar configuration")
+	}
+	err = conf.Devices.Validate()
+	if err != nil {
+		log.Fatal().Err(err).Msg("invalid device configuration")
+	}
+	if !conf.Devices.IsEnabled() {
+		log.Warn().Msg("no pin key configured. the staff pins and the device unlock are disabled")
+	}
+	err = conf.Server.Validate()
+	if err != nil {
+		log.Fatal().Err(err).Msg("invalid server configuration")
+	}
+	err = conf.Metrics.Validate()
+	if err != nil {
+		log.Fatal().Err(err).Msg("invalid metrics configuration")
+	}
+	if !conf.Metrics.IsEnabled() {
+		log.Warn().Msg("no metrics token configured. the metrics endpoint is disabled")
+	}
+	err = conf.Tracing.Validate()
+	if err != nil {
+		log.Fatal().Err(err).Msg("invalid tracing configuration")
+	}
+	err = conf.Localization.Validate()
+	if err != nil {
+		log.Fatal().Err(err).Msg("invalid localization configuration")
+	}
+	if conf.ErrorResponses.ExposesDetails() {
+		log.Warn().Msg("the causes of internal errors are exposed to the clients. do not use this in production")
+	}
+	// now log the effective configuration including the default values to
+	// the debug output. the secrets are masked, but the configuration still
+	// contains the addresses of the internal services
+	log.Debug().Interface("config", conf.Masked()).Msg("effective configuration")
 	globals.Configuration = conf
 
 	// since the configuration is valid, load the sql queries for the database
-	globals.SqlQueries, err = dotsql.LoadFromFile("./queries.sql")
+	queries, err := dotsql.LoadFromFile("./queries.sql")
 	if err != nil {
 		log.Fatal().Err(err).Msg("unable to load sql queries")
 	}
+	globals.SqlQueries = tracing.Queries{DotSql: queries}
 }
 
 // this function now loads the prepared errors from the error file and parses
@@ -110,7 +194,20 @@ func init() {
 	if err != nil {
 		log.Fatal().Err(err).Msg("unable to load error configuration file")
 	}
+	// every error needs to be available in each configured language, since
+	// the responses would otherwise mix the languages
+	languages := globals.Configuration.Localization.Languages
 	for _, e := range errors {
+		for _, lang := range languages {
+			if !e.HasTranslation(lang) {
+				available := make([]string, 0, len(e.Translations))
+				for translation := range e.Translations {
+					available = append(available, translation)
+				}
+				log.Fatal().Str("code", e.ErrorCode).Str("language", lang).Strs("available", available).
+					Msg("predefined error is not translated to the configured language")
+			}
+		}
 		e.InferHttpStatusText()
 		globals.Errors[e.ErrorCode] = e
 	}
@@ -186,10 +283,17 @@ func init() {
 	if err != nil {
 		log.Fatal().Err(err).Msg("unable to load sql file with initial table definitions")
 	}
-	for name, _ := range initQueries.QueryMap() {
-		_, err := initQueries.Query(globals.Database, name)
+	// the queries are executed in the order of the file, since later tables
+	// reference earlier ones and migrations need to run after the creation of
+	// their tables
+	initFile, err := os.ReadFile("./init.sql")
+	if err != nil {
+		log.Fatal().Err(err).Msg("unable to read sql file with initial table definitions")
+	}
+	for _, match := range queryNamePattern.FindAllStringSubmatch(string(initFile), -1) {
+		_, err := initQueries.Exec(globals.Database, match[1])
 		if err != nil {
-			log.Fatal().Err(err).Msg("unable to create needed database objects")
+			log.Fatal().Err(err).Str("query", match[1]).Msg("unable to create needed database objects")
 		}
 	}
 	log.Info().Msg("connected to postgres")
@@ -201,7 +305,6 @@ func init() {
 func init() {
 	// get the database configuration again
 	wp := globals.Configuration.WordPress
-	_ = wp.Validate()
 	// now build the dsn including the schema name
 	dsn := wp.BuildDSN()
 	// now try to open the connection
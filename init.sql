@@ -40,3 +40,203 @@ CREATE TABLE IF NOT EXISTS cinema_management.transactions
         REFERENCES cinema_management.cash_registers
             ON UPDATE RESTRICT ON DELETE RESTRICT
 );
+
+-- name: create-venue-table
+CREATE TABLE IF NOT EXISTS cinema_management.venues
+(
+    id       uuid DEFAULT gen_random_uuid() NOT NULL PRIMARY KEY,
+    name     text                           NOT NULL,
+    capacity integer                        NOT NULL CHECK (capacity >= 0)
+);
+
+-- name: create-screening-table
+CREATE TABLE IF NOT EXISTS cinema_management.screenings
+(
+    id         uuid DEFAULT gen_random_uuid() NOT NULL PRIMARY KEY,
+    title      text                           NOT NULL,
+    starts_at  timestamptz                    NOT NULL,
+    venue      uuid                           NOT NULL
+        REFERENCES cinema_management.venues
+            ON UPDATE RESTRICT ON DELETE RESTRICT,
+    capacity   integer CHECK (capacity >= 0),
+    wp_post_id bigint
+);
+
+-- name: create-ticket-table
+CREATE TABLE IF NOT EXISTS cinema_management.tickets
+(
+    id                uuid      DEFAULT gen_random_uuid() NOT NULL PRIMARY KEY,
+    screening         uuid                                NOT NULL
+        REFERENCES cinema_management.screenings
+            ON UPDATE RESTRICT ON DELETE RESTRICT,
+    transaction       uuid
+        REFERENCES cinema_management.transactions
+            ON UPDATE RESTRICT ON DELETE RESTRICT,
+    sold_by           text                                NOT NULL,
+    sold_at           timestamp DEFAULT NOW(),
+    capacity_override boolean   DEFAULT false             NOT NULL,
+    checked_in_at     timestamptz
+);
+
+-- name: create-reservation-table
+CREATE TABLE IF NOT EXISTS cinema_management.reservations
+(
+    id          uuid      DEFAULT gen_random_uuid() NOT NULL PRIMARY KEY,
+    screening   uuid                                NOT NULL
+        REFERENCES cinema_management.screenings
+            ON UPDATE RESTRICT ON DELETE RESTRICT,
+    name        text                                NOT NULL,
+    email       text                                NOT NULL,
+    seats       integer                             NOT NULL CHECK (seats > 0),
+    status      text      DEFAULT 'held'            NOT NULL
+        CHECK (status IN ('held', 'claimed', 'released', 'cancelled')),
+    created_at  timestamp DEFAULT NOW()             NOT NULL,
+    transaction uuid
+        REFERENCES cinema_management.transactions
+            ON UPDATE RESTRICT ON DELETE RESTRICT
+);
+
+-- name: create-wordpress-sync-table
+CREATE TABLE IF NOT EXISTS cinema_management.wordpress_sync
+(
+    screening  uuid                  NOT NULL PRIMARY KEY
+        REFERENCES cinema_management.screenings
+            ON UPDATE RESTRICT ON DELETE CASCADE,
+    wp_post_id bigint                NOT NULL,
+    attendance integer               NOT NULL,
+    revenue    numeric               NOT NULL,
+    synced_at  timestamptz DEFAULT NOW() NOT NULL
+);
+
+-- name: create-shift-table
+CREATE TABLE IF NOT EXISTS cinema_management.shifts
+(
+    id        uuid DEFAULT gen_random_uuid() NOT NULL PRIMARY KEY,
+    screening uuid                           NOT NULL
+        REFERENCES cinema_management.screenings
+            ON UPDATE RESTRICT ON DELETE CASCADE,
+    role      text                           NOT NULL,
+    required  integer                        NOT NULL CHECK (required > 0),
+    UNIQUE (screening, role)
+);
+
+-- name: create-shift-signup-table
+CREATE TABLE IF NOT EXISTS cinema_management.shift_signups
+(
+    shift          uuid                  NOT NULL
+        REFERENCES cinema_management.shifts
+            ON UPDATE RESTRICT ON DELETE CASCADE,
+    volunteer      text                  NOT NULL,
+    volunteer_name text                  NOT NULL,
+    signed_up_at   timestamptz DEFAULT NOW() NOT NULL,
+    PRIMARY KEY (shift, volunteer)
+);
+
+-- name: create-device-table
+CREATE TABLE IF NOT EXISTS cinema_management.devices
+(
+    id               uuid        DEFAULT gen_random_uuid() NOT NULL PRIMARY KEY,
+    name             text                                  NOT NULL,
+    register         uuid                                  NOT NULL
+        REFERENCES cinema_management.cash_registers
+            ON UPDATE RESTRICT ON DELETE RESTRICT,
+    roles            text[]                                NOT NULL,
+    key_hash         text                                  NOT NULL UNIQUE,
+    created_by       text                                  NOT NULL,
+    created_at       timestamptz DEFAULT NOW()             NOT NULL,
+    revoked_at       timestamptz,
+    unlocked_by      text,
+    unlocked_by_name text,
+    unlocked_at      timestamptz
+);
+
+-- name: create-staff-pin-table
+CREATE TABLE IF NOT EXISTS cinema_management.staff_pins
+(
+    subject    text                      NOT NULL PRIMARY KEY,
+    name       text                      NOT NULL,
+    pin_hash   text                      NOT NULL,
+    updated_at timestamptz DEFAULT NOW() NOT NULL
+);
+
+-- name: add-transaction-device-column
+ALTER TABLE cinema_management.transactions
+    ADD COLUMN IF NOT EXISTS device uuid
+        REFERENCES cinema_management.devices
+            ON UPDATE RESTRICT ON DELETE RESTRICT;
+
+-- name: add-device-pin-attempt-columns
+ALTER TABLE cinema_management.devices
+    ADD COLUMN IF NOT EXISTS failed_pin_attempts integer DEFAULT 0 NOT NULL,
+    ADD COLUMN IF NOT EXISTS pin_locked_until    timestamptz;
+
+-- name: drop-staff-pin-hash-index
+DROP INDEX IF EXISTS cinema_management.staff_pins_pin_hash_key;
+
+-- name: add-staff-pin-attempt-columns
+ALTER TABLE cinema_management.staff_pins
+    ADD COLUMN IF NOT EXISTS failed_pin_attempts integer DEFAULT 0 NOT NULL,
+    ADD COLUMN IF NOT EXISTS pin_locked_until    timestamptz;
+
+-- name: add-staff-pin-roles-column
+ALTER TABLE cinema_management.staff_pins
+    ADD COLUMN IF NOT EXISTS roles text[] DEFAULT '{}' NOT NULL;
+
+-- name: create-user-table
+CREATE TABLE IF NOT EXISTS cinema_management.users
+(
+    subject       text                      NOT NULL PRIMARY KEY,
+    name          text                      NOT NULL,
+    first_seen_at timestamptz DEFAULT NOW() NOT NULL,
+    last_seen_at  timestamptz DEFAULT NOW() NOT NULL
+);
+
+-- name: add-transaction-identity-columns
+ALTER TABLE cinema_management.transactions
+    ADD COLUMN IF NOT EXISTS by_subject text,
+    ADD COLUMN IF NOT EXISTS created_at timestamptz;
+
+-- name: set-transaction-created-at-default
+-- the default is set separately, since adding the column with a default would
+-- stamp the existing transactions with the time of the migration. their
+-- creation time is unknown and therefore stays empty
+ALTER TABLE cinema_management.transactions
+    ALTER COLUMN created_at SET DEFAULT NOW();
+
+-- name: create-audit-log-table
+CREATE TABLE IF NOT EXISTS cinema_management.audit_log
+(
+    id          bigint GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    occurred_at timestamptz DEFAULT NOW() NOT NULL,
+    actor       text                      NOT NULL,
+    actor_name  text                      NOT NULL,
+    device      uuid,
+    request_id  text                      NOT NULL,
+    action      text                      NOT NULL,
+    entity      text                      NOT NULL,
+    entity_id   text                      NOT NULL,
+    before      jsonb,
+    after       jsonb
+);
+
+-- name: create-audit-log-index
+CREATE INDEX IF NOT EXISTS audit_log_entity_idx
+    ON cinema_management.audit_log (entity, entity_id);
+
+-- name: create-audit-log-guard-function
+CREATE OR REPLACE FUNCTION cinema_management.reject_audit_log_change() RETURNS trigger AS
+$$
+BEGIN
+    RAISE EXCEPTION 'the audit log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+-- name: drop-audit-log-guard
+DROP TRIGGER IF EXISTS audit_log_append_only ON cinema_management.audit_log;
+
+-- name: create-audit-log-guard
+CREATE TRIGGER audit_log_append_only
+    BEFORE UPDATE OR DELETE OR TRUNCATE
+    ON cinema_management.audit_log
+    FOR EACH STATEMENT
+EXECUTE FUNCTION cinema_management.reject_audit_log_change();
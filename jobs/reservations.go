@@ -0,0 +1,46 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"digitales-filmmanagement-backend/globals"
+)
+
+// ReleaseReservations periodically releases every reservation that has not
+// been claimed until the configured duration before the start of its
+// screening. The released seats are available for ticket bookings afterward.
+// The job runs until the supplied context is cancelled
+func ReleaseReservations(ctx context.Context) {
+	c := globals.Configuration.Reservations
+	ticker := time.NewTicker(c.Interval())
+	defer ticker.Stop()
+	for {
+		releaseReservations(ctx, c.HoldDuration())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// releaseReservations executes a single run of the reservation release job
+func releaseReservations(ctx context.Context, holdDuration time.Duration) {
+	result, err := globals.SqlQueries.ExecContext(ctx, globals.Database, "release-expired-reservations",
+		holdDuration.Seconds())
+	if err != nil {
+		log.Error().Err(err).Msg("unable to release expired reservations")
+		return
+	}
+	released, err := result.RowsAffected()
+	if err != nil {
+		log.Warn().Err(err).Msg("unable to count released reservations")
+		return
+	}
+	if released > 0 {
+		log.Info().Int64("count", released).Msg("released unclaimed reservations")
+	}
+}
@@ -0,0 +1,111 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/blockloop/scan/v2"
+	"github.com/rs/zerolog/log"
+
+	"digitales-filmmanagement-backend/globals"
+	"digitales-filmmanagement-backend/types"
+)
+
+// SyncAttendance periodically writes the attendance and optionally the ticket
+// revenue of finished screenings into the post meta of their WordPress posts.
+// Screenings are only written again if their numbers changed since the last
+// run, and the values are always written as absolute numbers, therefore
+// repeated runs do not change the result. The job runs until the supplied
+// context is cancelled
+func SyncAttendance(ctx context.Context) {
+	c := globals.Configuration.WordPress
+	if !c.SyncAttendance {
+		log.Info().Msg("attendance synchronisation to wordpress is disabled")
+		return
+	}
+	ticker := time.NewTicker(c.Interval())
+	defer ticker.Stop()
+	for {
+		if err := syncAttendance(ctx); err != nil {
+			log.Error().Err(err).Msg("unable to synchronise attendance to wordpress")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// syncAttendance executes a single run of the attendance synchronisation
+func syncAttendance(ctx context.Context) error {
+	c := globals.Configuration.WordPress
+	rows, err := globals.SqlQueries.QueryContext(ctx, globals.Database, "get-unsynced-attendance",
+		c.FinishedAfter().Seconds())
+	if err != nil {
+		return err
+	}
+	var screenings []types.ScreeningAttendance
+	if err = scan.Rows(&screenings, rows); err != nil {
+		return err
+	}
+
+	for _, screening := range screenings {
+		meta := map[string]string{
+			*c.AttendanceMetaKey: strconv.Itoa(screening.Attendance),
+		}
+		if c.RevenueMetaKey != nil && *c.RevenueMetaKey != "" {
+			meta[*c.RevenueMetaKey] = strconv.FormatFloat(screening.Revenue, 'f', 2, 64)
+		}
+		if err = writePostMeta(ctx, screening.WordPressPostID, meta); err != nil {
+			return fmt.Errorf("unable to write post meta of post %d: %w", screening.WordPressPostID, err)
+		}
+		_, err = globals.SqlQueries.ExecContext(ctx, globals.Database, "upsert-wordpress-sync",
+			screening.Screening, screening.WordPressPostID, screening.Attendance, screening.Revenue)
+		if err != nil {
+			return err
+		}
+		log.Info().Int64("post", screening.WordPressPostID).Int("attendance", screening.Attendance).
+			Msg("synchronised attendance to wordpress")
+	}
+	return nil
+}
+
+// writePostMeta sets the supplied meta values of a WordPress post. Since the
+// post meta table has no unique key for the post and the meta key, existing
+// entries are updated and missing entries are inserted in a single database
+// transaction
+func writePostMeta(ctx context.Context, postId int64, meta map[string]string) error {
+	table := globals.Configuration.WordPress.Table("postmeta")
+	tx, err := globals.WpDatabase.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	// the rollback is a no-op if the transaction has been committed already
+	defer tx.Rollback()
+
+	for key, value := range meta {
+		var entries int
+		err = tx.QueryRowContext(ctx,
+			fmt.Sprintf("SELECT count(*) FROM %s WHERE post_id = ? AND meta_key = ? FOR UPDATE", table),
+			postId, key).Scan(&entries)
+		if err != nil {
+			return err
+		}
+		var statement string
+		var arguments []interface{}
+		if entries == 0 {
+			statement = fmt.Sprintf("INSERT INTO %s (post_id, meta_key, meta_value) VALUES (?, ?, ?)", table)
+			arguments = []interface{}{postId, key, value}
+		} else {
+			statement = fmt.Sprintf("UPDATE %s SET meta_value = ? WHERE post_id = ? AND meta_key = ?", table)
+			arguments = []interface{}{value, postId, key}
+		}
+		if _, err = tx.ExecContext(ctx, statement, arguments...); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,97 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// namespace is prepended to the names of every metric of the backend
+const namespace = "filmmanagement"
+
+var requestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: namespace,
+	Name:      "http_request_duration_seconds",
+	Help:      "Duration of the handled http requests by route pattern and status code",
+	Buckets:   prometheus.DefBuckets,
+}, []string{"method", "route", "status"})
+
+var userInfoDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: namespace,
+	Name:      "userinfo_request_duration_seconds",
+	Help:      "Duration of the requests to the userinfo endpoints of the authorization servers",
+	Buckets:   prometheus.DefBuckets,
+}, []string{"provider", "result"})
+
+var transactions = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Name:      "transactions_total",
+	Help:      "Number of transactions booked by register",
+}, []string{"register"})
+
+var revenue = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Name:      "revenue_euros_total",
+	Help:      "Sum of the positive transaction amounts by register",
+}, []string{"register"})
+
+var payouts = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Name:      "payouts_euros_total",
+	Help:      "Sum of the negative transaction amounts by register",
+}, []string{"register"})
+
+var ticketsSold = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Name:      "tickets_sold_total",
+	Help:      "Number of tickets sold by register",
+}, []string{"register"})
+
+// Handler returns the http handler which exposes the metrics in the
+// Prometheus text format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// RegisterDatabases exposes the statistics of the connection pools of both
+// databases
+func RegisterDatabases(database *sql.DB, wpDatabase *sql.DB) {
+	prometheus.MustRegister(collectors.NewDBStatsCollector(database, "postgres"))
+	prometheus.MustRegister(collectors.NewDBStatsCollector(wpDatabase, "wordpress"))
+}
+
+// ObserveRequest records the duration of a handled http request. The route
+// pattern is used instead of the path, since the path contains the ids of
+// the requested entities
+func ObserveRequest(method string, route string, status int, duration time.Duration) {
+	requestDuration.WithLabelValues(method, route, strconv.Itoa(status)).Observe(duration.Seconds())
+}
+
+// ObserveUserInfoRequest records the duration of a request to the userinfo
+// endpoint of an authorization server
+func ObserveUserInfoRequest(provider string, result string, duration time.Duration) {
+	userInfoDuration.WithLabelValues(provider, result).Observe(duration.Seconds())
+}
+
+// RecordTransaction counts a transaction booked in the register. It should
+// only be called once the transaction has been committed
+func RecordTransaction(register string, amount float64) {
+	transactions.WithLabelValues(register).Inc()
+	if amount >= 0 {
+		revenue.WithLabelValues(register).Add(amount)
+	} else {
+		payouts.WithLabelValues(register).Add(-amount)
+	}
+}
+
+// RecordTicketSale counts the tickets sold in the register. It should only be
+// called once the sale has been committed
+func RecordTicketSale(register string, count int) {
+	ticketsSold.WithLabelValues(register).Add(float64(count))
+}
@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	chiMiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// accessLogEntry collects the details of a request which are only known to
+// the inner handlers, e.g. the authenticated user
+type accessLogEntry struct {
+	user   string
+	device string
+}
+
+// accessLogKey is the context key of the accessLogEntry of a request
+const accessLogKey = "accessLog"
+
+// redactedHeaders contains the request headers whose values are never
+// written to the access log, since they contain credentials
+var redactedHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+}
+
+// AccessLog writes a structured log entry for every request once it has been
+// handled. Requests answered with a server error are logged with the error
+// level, requests answered with a client error with the warn level and
+// every other request with the info level. The request headers are only
+// logged with the debug level and the credentials they contain are redacted.
+// Since errors are rendered after the handler returned, the middleware needs
+// to be used before the ErrorHandler middleware and after the RequestID and
+// RealIP middlewares
+func AccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		entry := &accessLogEntry{}
+		wrappedWriter := chiMiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(wrappedWriter, r.WithContext(context.WithValue(r.Context(), accessLogKey, entry)))
+
+		status := wrappedWriter.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		var event *zerolog.Event
+		switch {
+		case status >= 500:
+			event = log.Error()
+		case status >= 400:
+			event = log.Warn()
+		default:
+			event = log.Info()
+		}
+		if !event.Enabled() {
+			return
+		}
+		event = event.Str("requestId", chiMiddleware.GetReqID(r.Context())).
+			Str("method", r.Method).
+			Str("path", r.URL.Path).
+			Str("remoteAddr", r.RemoteAddr).
+			Str("userAgent", r.UserAgent()).
+			Int("status", status).
+			Int("size", wrappedWriter.BytesWritten()).
+			Dur("duration", time.Since(start))
+		if routeContext := chi.RouteContext(r.Context()); routeContext != nil && routeContext.RoutePattern() != "" {
+			event = event.Str("route", routeContext.RoutePattern())
+		}
+		if entry.user != "" {
+			event = event.Str("user", entry.user)
+		}
+		if entry.device != "" {
+			event = event.Str("device", entry.device)
+		}
+		if zerolog.GlobalLevel() <= zerolog.DebugLevel {
+			event = event.Dict("headers", redactHeaders(r.Header))
+		}
+		event.Msg("handled request")
+	})
+}
+
+// logIdentity stores the user and the device which authenticated the request
+// in the access log entry of the request
+func logIdentity(ctx context.Context, user string, device *string) {
+	entry, hasEntry := ctx.Value(accessLogKey).(*accessLogEntry)
+	if !hasEntry {
+		return
+	}
+	entry.user = user
+	if device != nil {
+		entry.device = *device
+	}
+}
+
+// redactHeaders converts the request headers into a log dictionary and
+// replaces the values of the headers containing credentials
+func redactHeaders(headers http.Header) *zerolog.Event {
+	dict := zerolog.Dict()
+	for name, values := range headers {
+		if redactedHeaders[name] {
+			dict = dict.Str(name, "[REDACTED]")
+			continue
+		}
+		dict = dict.Strs(name, values)
+	}
+	return dict
+}
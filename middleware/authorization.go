@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"digitales-filmmanagement-backend/types"
+)
+
+// RequireRole only lets requests of users pass which have been granted at
+// least one of the supplied roles. Users with the admin role are allowed to
+// access every route. Requests of other users are answered with the
+// "FORBIDDEN" error. Requests of devices which have not been unlocked by a
+// staff member are answered with the "DEVICE_LOCKED" error. The middleware
+// needs to be used after UserInfo, since it reads the roles from the request
+// context
+//
+// Example:
+//
+//	r.With(middleware.RequireRole(types.RoleTreasurer)).Get("/items", middleware.Handle(itemStatistics))
+func RequireRole(roles ...types.Role) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			if DeviceLocked(ctx) {
+				Fail(w, r, types.ErrorWithCode("DEVICE_LOCKED"))
+				return
+			}
+			if !HasRole(ctx, roles...) {
+				Fail(w, r, types.ErrorWithCode("FORBIDDEN"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// HasRole reports if the user of the request has been granted at least one
+// of the supplied roles or the admin role
+func HasRole(ctx context.Context, roles ...types.Role) bool {
+	grantedRoles, _ := ctx.Value("roles").([]types.Role)
+	for _, grantedRole := range grantedRoles {
+		if grantedRole == types.RoleAdmin {
+			return true
+		}
+		for _, role := range roles {
+			if grantedRole == role {
+				return true
+			}
+		}
+	}
+	return false
+}
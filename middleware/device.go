@@ -0,0 +1,135 @@
+package middleware
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+
+	"digitales-filmmanagement-backend/devices"
+	"digitales-filmmanagement-backend/globals"
+	"digitales-filmmanagement-backend/types"
+)
+
+// knownStaffRoles maps the subjects of the staff members whose roles have
+// been stored since the start of the backend to their sorted roles
+var knownStaffRoles sync.Map
+
+// authenticateDevice looks up the device which the supplied key was issued to
+// and stores the device, its register and its roles in the returned context.
+// If a staff member unlocked the device, their identity is stored as user of
+// the request and the request is limited to the roles shared by the device
+// and the staff member. If the key is unknown or has been revoked, the code of
+// the error which should be sent to the client is returned
+func authenticateDevice(ctx context.Context, key string) (context.Context, string, error) {
+	row, err := globals.SqlQueries.QueryRowContext(ctx, globals.Database, "get-device-by-key", devices.HashKey(key))
+	if err != nil {
+		return ctx, "", err
+	}
+	var deviceId, register string
+	var roleNames, staffRoleNames []string
+	var unlockedBy, unlockedByName *string
+	err = row.Scan(&deviceId, &register, pq.Array(&roleNames), &unlockedBy, &unlockedByName, pq.Array(&staffRoleNames))
+	if errors.Is(err, sql.ErrNoRows) {
+		return ctx, "UNAUTHORIZED", nil
+	}
+	if err != nil {
+		return ctx, "", err
+	}
+
+	roles := toRoles(roleNames)
+	ctx = context.WithValue(ctx, "device", deviceId)
+	ctx = context.WithValue(ctx, "deviceRegister", register)
+	ctx = context.WithValue(ctx, "deviceRoles", roles)
+	ctx = context.WithValue(ctx, "roles", roles)
+	if unlockedBy != nil && unlockedByName != nil {
+		ctx = context.WithValue(ctx, "user", *unlockedByName)
+		ctx = context.WithValue(ctx, "userId", *unlockedBy)
+		ctx = context.WithValue(ctx, "roles", SharedRoles(roles, toRoles(staffRoleNames)))
+	}
+	return ctx, "", nil
+}
+
+// toRoles converts the role names stored in the database into roles
+func toRoles(names []string) []types.Role {
+	roles := make([]types.Role, 0, len(names))
+	for _, name := range names {
+		roles = append(roles, types.Role(name))
+	}
+	return roles
+}
+
+// SharedRoles returns the roles of the device which the staff member holds as
+// well. Staff members with the admin role share every role of the device
+func SharedRoles(deviceRoles []types.Role, staffRoles []types.Role) []types.Role {
+	shared := make([]types.Role, 0, len(deviceRoles))
+	for _, deviceRole := range deviceRoles {
+		for _, staffRole := range staffRoles {
+			if staffRole == deviceRole || staffRole == types.RoleAdmin {
+				shared = append(shared, deviceRole)
+				break
+			}
+		}
+	}
+	return shared
+}
+
+// rememberStaffRoles stores the current roles of a staff member with the PIN
+// of the member whenever their roles change, since the roles limit the roles
+// of the devices unlocked by the member. Failures are only logged, since the
+// roles are updated on the next request of the member again
+func rememberStaffRoles(ctx context.Context, subject string, roles []types.Role) {
+	names := make([]string, 0, len(roles))
+	for _, role := range roles {
+		names = append(names, string(role))
+	}
+	sort.Strings(names)
+	joinedNames := strings.Join(names, ",")
+	if knownNames, known := knownStaffRoles.Load(subject); known && knownNames == joinedNames {
+		return
+	}
+	_, err := globals.SqlQueries.ExecContext(ctx, globals.Database, "update-staff-pin-roles", subject, pq.Array(names))
+	if err != nil {
+		log.Error().Err(err).Str("user", subject).Msg("unable to store staff roles")
+		return
+	}
+	knownStaffRoles.Store(subject, joinedNames)
+}
+
+// Device returns the UUID of the device which authenticated the request. If
+// the request was authenticated using an OpenID Connect access token, nil is
+// returned
+func Device(ctx context.Context) *string {
+	deviceId, isDevice := ctx.Value("device").(string)
+	if !isDevice {
+		return nil
+	}
+	return &deviceId
+}
+
+// DeviceRoles returns the roles granted to the device which authenticated the
+// request, regardless of the roles of the staff member who unlocked it
+func DeviceRoles(ctx context.Context) []types.Role {
+	roles, _ := ctx.Value("deviceRoles").([]types.Role)
+	return roles
+}
+
+// DeviceLocked reports if the request was sent by a device which has not been
+// unlocked by a staff member
+func DeviceLocked(ctx context.Context) bool {
+	userId, _ := ctx.Value("userId").(string)
+	return Device(ctx) != nil && userId == ""
+}
+
+// AllowsRegister reports if the request may book transactions in the
+// supplied register. Devices are bound to a single register, while users
+// authenticated using OpenID Connect may use every register
+func AllowsRegister(ctx context.Context, registerId string) bool {
+	register, isDevice := ctx.Value("deviceRegister").(string)
+	return !isDevice || register == registerId
+}
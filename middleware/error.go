@@ -4,103 +4,189 @@ import (
 	"context"
 	"digitales-filmmanagement-backend/types"
 	"encoding/json"
+	"errors"
+	"github.com/ggicci/httpin"
+	chiMiddleware "github.com/go-chi/chi/v5/middleware"
 	"github.com/rs/zerolog/log"
+	"golang.org/x/text/language"
 	"net/http"
 )
 
-// NativeErrorHandler allows the global handling and wrapping of native errors
-// occurring in API calls. The function needs the service name as a parameter
-// to correctly generate the error code used in the wisdomType.WISdoMError
-//
-// To access the channel added to the request context in a http handler use
-// the following call:
-//
-//	nativeErrorChannel := r.Context().Value("nativeErrorChannel").(chan error)
-//
-// To render an error, just send it into the channel using the following syntax:
-//
-//	nativeErrorChannel<-err
-func NativeErrorHandler(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// create a new channel
-		c := make(chan error)
-		a := make(chan bool)
-		// now access the request context
-		ctx := r.Context()
-		ctx = context.WithValue(ctx, "nativeErrorChannel", c)
-		ctx = context.WithValue(ctx, "nativeErrorHandled", a)
-		// use a go function to listen to the channel and output the
-		// request error to the client using json
-		go func() {
-			for {
-				select {
-				case err := <-c:
-					e := types.APIError{}
-					e.WrapError(err)
-					w.Header().Set("Content-Type", "text/json")
-					w.WriteHeader(500)
-					encodingErr := json.NewEncoder(w).Encode(e)
-					if encodingErr != nil {
-						log.Error().Err(encodingErr).Msg("unable to send error")
-					}
-					a <- true
-					return
-				}
-			}
-		}()
-		// now let the next handler handle the request
-		next.ServeHTTP(w, r.WithContext(ctx))
-	})
+// errorSlot holds the error of a request until it is rendered by the
+// ErrorHandler middleware
+type errorSlot struct {
+	err error
 }
 
-// APIErrorHandler allows the global handling and wrapping of WISdoM errors
-// occurring in API calls. The function needs the mapping of predefined error
-// messages using the wisdomType.WISdoMError
-//
-// To access the channel added to the request context in a http handler use
-// the following call:
+// errorSlotKey is the context key of the errorSlot of a request
+const errorSlotKey = "errorSlot"
+
+// HandlerFunc is a http handler which returns the error that occurred while
+// handling the request instead of writing it. Errors carrying the code of a
+// predefined types.APIError are created using types.ErrorWithCode, every
+// other error is rendered as internal error.
 //
-//	wisdomErrorChannel := r.Context().Value("wisdomErrorChannel").(chan string)
+// A handler may only return an error as long as it has not written a
+// response yet. Errors occurring afterwards should only be logged
+type HandlerFunc func(w http.ResponseWriter, r *http.Request) error
+
+// Handle converts the HandlerFunc into a http.HandlerFunc which passes the
+// returned error to the ErrorHandler middleware
 //
-// To render the error just send the error code into the channel:
+// Example:
 //
-//	wisdomErrorChannel <- "test"
+//	r.Get("/", middleware.Handle(getAllVenues))
+func Handle(handler HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := handler(w, r); err != nil {
+			Fail(w, r, err)
+		}
+	}
+}
+
+// Fail passes the error to the ErrorHandler middleware, which renders it
+// once the request has been handled. Middlewares use the function to reject
+// requests without calling the next handler. If the ErrorHandler middleware
+// is not used, the error is logged and rendered as internal error right away
+func Fail(w http.ResponseWriter, r *http.Request, err error) {
+	slot, hasSlot := r.Context().Value(errorSlotKey).(*errorSlot)
+	if !hasSlot {
+		log.Error().Err(err).Msg("error handler not available. rendering the error as internal error")
+		var e types.APIError
+		e.WrapError(err)
+		problem := e.Problem()
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.WriteHeader(problem.Status)
+		if encodingErr := json.NewEncoder(w).Encode(problem); encodingErr != nil {
+			log.Error().Err(encodingErr).Msg("unable to send error")
+		}
+		return
+	}
+	slot.err = err
+}
+
+// ErrorHandler renders the errors returned by the handlers and middlewares
+// of a request as "application/problem+json" response. Errors carrying the
+// code of a predefined types.APIError are rendered using the predefined error
+// and its status code. Every other error, including errors with an unknown
+// code, is rendered as internal error. The id of the request is used as
+// instance of the problem, therefore the middleware needs to be used after
+// the RequestID middleware.
 //
-// If an unregistered error code is used a panic will be released in the go
-// function handling the actual rendering
-func APIErrorHandler(errors map[string]types.APIError) func(http.Handler) http.Handler {
+// The title and the description of the error are rendered in the language
+// matching the Accept-Language header of the request best. If none of the
+// supplied languages matches, the first language is used. The cause of
+// internal errors is only included in the response if exposeDetails is set
+func ErrorHandler(apiErrors map[string]types.APIError, languages []string, exposeDetails bool) func(http.Handler) http.Handler {
+	tags := make([]language.Tag, len(languages))
+	for i, lang := range languages {
+		tags[i] = language.Make(lang)
+	}
+	matcher := language.NewMatcher(tags)
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// create a new channel
-			c := make(chan string)
-			a := make(chan bool)
-			// now access the request context
-			ctx := r.Context()
-			ctx = context.WithValue(ctx, "apiErrorChannel", c)
-			ctx = context.WithValue(ctx, "apiErrorHandled", a)
-			// use a go function to listen to the channel and output the
-			// request error to the client using json
-			go func() {
-				for {
-					select {
-					case errorCode := <-c:
-						e, errorPresent := errors[errorCode]
-						if !errorPresent {
-							panic("using unregistered error")
-						}
-						w.Header().Set("Content-Type", "text/json")
-						w.WriteHeader(500)
-						encodingErr := json.NewEncoder(w).Encode(e)
-						if encodingErr != nil {
-							log.Error().Err(encodingErr).Msg("unable to send error")
-						}
-						a <- true
-						return
-					}
-				}
-			}()
-			// now let the next handler handle the request
+			slot := &errorSlot{}
+			ctx := context.WithValue(r.Context(), errorSlotKey, slot)
 			next.ServeHTTP(w, r.WithContext(ctx))
+			if slot.err != nil {
+				// the header is only parsed if an error occurred, since most
+				// requests are answered without an error
+				requested, _, _ := language.ParseAcceptLanguage(r.Header.Get("Accept-Language"))
+				_, index, _ := matcher.Match(requested...)
+				writeError(w, r, apiErrors, languages[index], exposeDetails, slot.err)
+			}
 		})
 	}
 }
+
+// writeError renders the error as RFC 7807 problem details object using the
+// status code of the predefined error and the texts in the supplied language.
+// Internal errors are rendered with a generic description unless the details
+// should be exposed. Their cause is logged together with the id of the
+// request, which is sent to the client as instance of the problem to allow
+// finding the log entry. The stack is only logged if it was attached where
+// the error was created, since a stack captured while rendering the error
+// would not point to its origin
+func writeError(w http.ResponseWriter, r *http.Request, apiErrors map[string]types.APIError, lang string, exposeDetails bool, err error) {
+	requestId := chiMiddleware.GetReqID(r.Context())
+	var e types.APIError
+	var fields []types.FieldError
+	var codedError *types.CodedError
+	if errors.As(err, &codedError) {
+		apiError, known := apiErrors[codedError.Code]
+		if known {
+			e = apiError.Localize(lang)
+			fields = localizeFields(apiErrors, lang, codedError.Fields)
+		} else {
+			log.Error().Stack().Err(err).Str("requestId", requestId).Str("code", codedError.Code).
+				Msg("using unregistered error")
+			e.WrapError(err)
+		}
+	} else {
+		log.Error().Stack().Err(err).Str("requestId", requestId).Str("method", r.Method).Str("path", r.URL.Path).
+			Msg("error while handling request")
+		e.WrapError(err)
+	}
+	if e.ErrorCode == "INTERNAL_ERROR" {
+		if internalError, known := apiErrors["INTERNAL_ERROR"]; known {
+			e = internalError.Localize(lang)
+		}
+		if exposeDetails {
+			e.ErrorDescription = err.Error()
+		}
+	}
+
+	problem := e.Problem()
+	problem.Instance = requestId
+	problem.Errors = fields
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("Content-Language", lang)
+	w.WriteHeader(problem.Status)
+	encodingErr := json.NewEncoder(w).Encode(problem)
+	if encodingErr != nil {
+		log.Error().Err(encodingErr).Msg("unable to send error")
+	}
+}
+
+// localizeFields returns a copy of the invalid fields using the translated
+// description of their predefined error as detail. Fields with an unknown
+// code are rendered without a detail
+func localizeFields(apiErrors map[string]types.APIError, lang string, fields []types.FieldError) []types.FieldError {
+	if len(fields) == 0 {
+		return nil
+	}
+	localized := make([]types.FieldError, len(fields))
+	for i, field := range fields {
+		localized[i] = field
+		apiError, known := apiErrors[field.Code]
+		if !known {
+			log.Error().Str("code", field.Code).Str("field", field.Field).Msg("using unregistered field error")
+			continue
+		}
+		localized[i].Detail = apiError.Localize(lang).ErrorDescription
+	}
+	return localized
+}
+
+// NotFound renders the "NOT_FOUND" error for requests to unknown routes
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	Fail(w, r, types.ErrorWithCode("NOT_FOUND"))
+}
+
+// MethodNotAllowed renders the "METHOD_NOT_ALLOWED" error for requests using
+// a method which is not supported by the route
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	Fail(w, r, types.ErrorWithCode("METHOD_NOT_ALLOWED"))
+}
+
+// InvalidInput renders the "INVALID_QUERY_PARAMETER" error for requests whose
+// parameters cannot be decoded. It is used as error handler of the httpin
+// package
+func InvalidInput(w http.ResponseWriter, r *http.Request, err error) {
+	var fields []types.FieldError
+	var invalidField *httpin.InvalidFieldError
+	if errors.As(err, &invalidField) {
+		fields = append(fields, types.FieldError{Field: invalidField.Field, Code: "FIELD_INVALID_VALUE"})
+	}
+	Fail(w, r, &types.CodedError{Code: "INVALID_QUERY_PARAMETER", Err: err, Fields: fields})
+}
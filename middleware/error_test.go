@@ -0,0 +1,219 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	chiMiddleware "github.com/go-chi/chi/v5/middleware"
+
+	"digitales-filmmanagement-backend/types"
+)
+
+// testErrors contains the predefined errors used by the tests
+var testErrors = map[string]types.APIError{
+	"INTERNAL_ERROR": {
+		ErrorCode:      "INTERNAL_ERROR",
+		HttpStatusCode: http.StatusInternalServerError,
+		Translations: map[string]types.ErrorTranslation{
+			"de": {Title: "Interner Fehler", Description: "Ein interner Fehler ist aufgetreten"},
+			"en": {Title: "Internal Error", Description: "An internal error occurred"},
+		},
+	},
+	"FIELD_EMPTY": {
+		ErrorCode:      "FIELD_EMPTY",
+		HttpStatusCode: http.StatusBadRequest,
+		Translations: map[string]types.ErrorTranslation{
+			"de": {Title: "Leeres Feld", Description: "Das Feld darf nicht leer sein"},
+			"en": {Title: "Empty Field", Description: "The field may not be empty"},
+		},
+	},
+	"SCREENING_NOT_FOUND": {
+		ErrorCode:      "SCREENING_NOT_FOUND",
+		HttpStatusCode: http.StatusNotFound,
+		Translations: map[string]types.ErrorTranslation{
+			"de": {Title: "Vorstellung nicht gefunden", Description: "Die Vorstellung existiert nicht"},
+			"en": {Title: "Screening Not Found", Description: "The screening does not exist"},
+		},
+	},
+}
+
+// failingMiddleware rejects every request without calling the next handler
+func failingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Fail(w, r, types.ErrorWithCode("SCREENING_NOT_FOUND"))
+	})
+}
+
+func TestErrorHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		handlerErr     error
+		middleware     func(http.Handler) http.Handler
+		acceptLanguage string
+		exposeDetails  bool
+		wantStatus     int
+		wantCode       string
+		wantTitle      string
+		wantDetail     string
+		wantLanguage   string
+		wantFields     []string
+		wantHandler    bool
+	}{
+		{
+			name:         "known code",
+			handlerErr:   types.ErrorWithCode("SCREENING_NOT_FOUND"),
+			wantStatus:   http.StatusNotFound,
+			wantCode:     "SCREENING_NOT_FOUND",
+			wantTitle:    "Vorstellung nicht gefunden",
+			wantDetail:   "Die Vorstellung existiert nicht",
+			wantLanguage: "de",
+			wantHandler:  true,
+		},
+		{
+			name:           "known code in requested language",
+			handlerErr:     types.ErrorWithCode("SCREENING_NOT_FOUND"),
+			acceptLanguage: "en-US,en;q=0.9",
+			wantStatus:     http.StatusNotFound,
+			wantCode:       "SCREENING_NOT_FOUND",
+			wantTitle:      "Screening Not Found",
+			wantDetail:     "The screening does not exist",
+			wantLanguage:   "en",
+			wantHandler:    true,
+		},
+		{
+			name: "known code with invalid fields",
+			handlerErr: types.ValidationError("SCREENING_NOT_FOUND",
+				types.FieldError{Field: "title", Code: "FIELD_EMPTY"}, types.FieldError{Field: "venue", Code: "UNKNOWN_CODE"}),
+			acceptLanguage: "en",
+			wantStatus:     http.StatusNotFound,
+			wantCode:       "SCREENING_NOT_FOUND",
+			wantTitle:      "Screening Not Found",
+			wantDetail:     "The screening does not exist",
+			wantLanguage:   "en",
+			wantFields:     []string{"The field may not be empty", ""},
+			wantHandler:    true,
+		},
+		{
+			name:         "unknown code",
+			handlerErr:   types.ErrorWithCode("UNKNOWN_CODE"),
+			wantStatus:   http.StatusInternalServerError,
+			wantCode:     "INTERNAL_ERROR",
+			wantTitle:    "Interner Fehler",
+			wantDetail:   "Ein interner Fehler ist aufgetreten",
+			wantLanguage: "de",
+			wantHandler:  true,
+		},
+		{
+			name:         "plain error",
+			handlerErr:   errors.New("connection refused"),
+			wantStatus:   http.StatusInternalServerError,
+			wantCode:     "INTERNAL_ERROR",
+			wantTitle:    "Interner Fehler",
+			wantDetail:   "Ein interner Fehler ist aufgetreten",
+			wantLanguage: "de",
+			wantHandler:  true,
+		},
+		{
+			name:          "plain error with exposed details",
+			handlerErr:    errors.New("connection refused"),
+			exposeDetails: true,
+			wantStatus:    http.StatusInternalServerError,
+			wantCode:      "INTERNAL_ERROR",
+			wantTitle:     "Interner Fehler",
+			wantDetail:    "connection refused",
+			wantLanguage:  "de",
+			wantHandler:   true,
+		},
+		{
+			name:         "middleware failing before next",
+			middleware:   failingMiddleware,
+			wantStatus:   http.StatusNotFound,
+			wantCode:     "SCREENING_NOT_FOUND",
+			wantTitle:    "Vorstellung nicht gefunden",
+			wantDetail:   "Die Vorstellung existiert nicht",
+			wantLanguage: "de",
+			wantHandler:  false,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			handlerCalled := false
+			var handler http.Handler = Handle(func(w http.ResponseWriter, r *http.Request) error {
+				handlerCalled = true
+				return test.handlerErr
+			})
+			if test.middleware != nil {
+				handler = test.middleware(handler)
+			}
+			handler = chiMiddleware.RequestID(ErrorHandler(testErrors, []string{"de", "en"}, test.exposeDetails)(handler))
+
+			request := httptest.NewRequest(http.MethodGet, "/screenings", nil)
+			if test.acceptLanguage != "" {
+				request.Header.Set("Accept-Language", test.acceptLanguage)
+			}
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+
+			if handlerCalled != test.wantHandler {
+				t.Errorf("handler called = %v, want %v", handlerCalled, test.wantHandler)
+			}
+			if recorder.Code != test.wantStatus {
+				t.Errorf("status = %d, want %d", recorder.Code, test.wantStatus)
+			}
+			if contentType := recorder.Header().Get("Content-Type"); contentType != "application/problem+json" {
+				t.Errorf("content type = %q, want %q", contentType, "application/problem+json")
+			}
+			if lang := recorder.Header().Get("Content-Language"); lang != test.wantLanguage {
+				t.Errorf("content language = %q, want %q", lang, test.wantLanguage)
+			}
+			var problem types.Problem
+			if err := json.NewDecoder(recorder.Body).Decode(&problem); err != nil {
+				t.Fatalf("unable to decode problem: %v", err)
+			}
+			if problem.Code != test.wantCode || problem.Type != types.ProblemTypePrefix+test.wantCode {
+				t.Errorf("code = %q, type = %q, want code %q", problem.Code, problem.Type, test.wantCode)
+			}
+			if problem.Status != test.wantStatus {
+				t.Errorf("problem status = %d, want %d", problem.Status, test.wantStatus)
+			}
+			if problem.Title != test.wantTitle {
+				t.Errorf("title = %q, want %q", problem.Title, test.wantTitle)
+			}
+			if problem.Detail != test.wantDetail {
+				t.Errorf("detail = %q, want %q", problem.Detail, test.wantDetail)
+			}
+			if problem.Instance == "" {
+				t.Error("instance is empty, want the request id")
+			}
+			if len(problem.Errors) != len(test.wantFields) {
+				t.Fatalf("field errors = %d, want %d", len(problem.Errors), len(test.wantFields))
+			}
+			for i, detail := range test.wantFields {
+				if problem.Errors[i].Detail != detail {
+					t.Errorf("field detail = %q, want %q", problem.Errors[i].Detail, detail)
+				}
+			}
+		})
+	}
+}
+
+func TestFailWithoutErrorHandler(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	Handle(func(w http.ResponseWriter, r *http.Request) error {
+		return errors.New("connection refused")
+	}).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+	// without the error handler, the error is rendered as internal error
+	if recorder.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want %d", recorder.Code, http.StatusInternalServerError)
+	}
+	var problem types.Problem
+	if err := json.NewDecoder(recorder.Body).Decode(&problem); err != nil {
+		t.Fatalf("unable to decode problem: %v", err)
+	}
+	if problem.Code != "INTERNAL_ERROR" {
+		t.Errorf("code = %q, want %q", problem.Code, "INTERNAL_ERROR")
+	}
+}
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+
+	"digitales-filmmanagement-backend/globals"
+)
+
+// displayNameClaims contains the claims which are used as display name of a
+// user, in the order of their precedence
+var displayNameClaims = []string{"name", "given_name", "preferred_username", "email"}
+
+// knownUsers maps the subjects of the users which have been stored in the
+// users table since the start of the backend to their display name
+var knownUsers sync.Map
+
+// displayName returns the display name of the user described by the userinfo
+// claims. If the authorization server has a configured name claim, only this
+// claim is used. If none of the name claims is set, the subject is used
+// instead
+func displayName(userInfo map[string]interface{}, subject string, nameClaim *string) string {
+	claims := displayNameClaims
+	if nameClaim != nil {
+		claims = []string{*nameClaim}
+	}
+	for _, claim := range claims {
+		if name, isString := userInfo[claim].(string); isString && strings.TrimSpace(name) != "" {
+			return strings.TrimSpace(name)
+		}
+	}
+	return subject
+}
+
+// rememberUser stores the user in the users table on their first login and
+// whenever their display name changes. Failures are only logged, since the
+// transactions contain a snapshot of the display name
+func rememberUser(ctx context.Context, subject string, name string) {
+	if knownName, known := knownUsers.Load(subject); known && knownName == name {
+		return
+	}
+	_, err := globals.SqlQueries.ExecContext(ctx, globals.Database, "upsert-user", subject, name)
+	if err != nil {
+		log.Error().Err(err).Str("user", subject).Msg("unable to store user")
+		return
+	}
+	knownUsers.Store(subject, name)
+}
+
+// UserID returns the subject of the user responsible for the request. If the
+// request was sent by a locked device, an empty string is returned
+func UserID(ctx context.Context) string {
+	userId, _ := ctx.Value("userId").(string)
+	return userId
+}
+
+// UserName returns the display name of the user responsible for the request.
+// If the request was sent by a locked device, an empty string is returned
+func UserName(ctx context.Context) string {
+	name, _ := ctx.Value("user").(string)
+	return name
+}
@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"context"
+	"strings"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/rs/zerolog/log"
+
+	"digitales-filmmanagement-backend/config"
+	"digitales-filmmanagement-backend/globals"
+)
+
+// newTokenVerifier creates a verifier validating JWT access tokens locally
+// against the key set of the authorization server. The key set is downloaded
+// on the first validation and downloaded again whenever a token is signed
+// with an unknown key, which allows the authorization server to rotate its
+// keys at any time. If the configuration does not contain the issuer and the
+// key set, nil is returned and every token is validated using the userinfo
+// endpoint
+func newTokenVerifier(c config.OpenIdConnectConfiguration) *oidc.IDTokenVerifier {
+	if c.Issuer == nil || c.JwksURI == nil {
+		log.Warn().Msg("no key set available. validating every token using the userinfo endpoint")
+		return nil
+	}
+	keySetContext := oidc.ClientContext(context.Background(), globals.HttpClient)
+	keySet := oidc.NewRemoteKeySet(keySetContext, *c.JwksURI)
+	verifierConfig := &oidc.Config{
+		SupportedSigningAlgs: []string{
+			oidc.RS256, oidc.RS384, oidc.RS512,
+			oidc.ES256, oidc.ES384, oidc.ES512,
+			oidc.PS256, oidc.PS384, oidc.PS512,
+			oidc.EdDSA,
+		},
+	}
+	if c.Audience != nil {
+		verifierConfig.ClientID = *c.Audience
+	} else {
+		log.Warn().Msg("no audience configured. the audience of access tokens will not be checked")
+		verifierConfig.SkipClientIDCheck = true
+	}
+	return oidc.NewVerifier(*c.Issuer, keySet, verifierConfig)
+}
+
+// bearerToken removes the "Bearer" scheme from the authorization header value
+func bearerToken(authHeaderValue string) string {
+	scheme, token, found := strings.Cut(authHeaderValue, " ")
+	if found && strings.EqualFold(scheme, "bearer") {
+		return strings.TrimSpace(token)
+	}
+	return authHeaderValue
+}
+
+// isJWT reports if the token has the structure of a signed JWT. Every other
+// token is treated as opaque token
+func isJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+// verifyToken validates the JWT access token locally and returns its claims
+func verifyToken(ctx context.Context, verifier *oidc.IDTokenVerifier, token string) (map[string]interface{}, error) {
+	verifiedToken, err := verifier.Verify(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	claims := make(map[string]interface{})
+	err = verifiedToken.Claims(&claims)
+	return claims, err
+}
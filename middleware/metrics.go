@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	chiMiddleware "github.com/go-chi/chi/v5/middleware"
+
+	"digitales-filmmanagement-backend/metrics"
+	"digitales-filmmanagement-backend/types"
+)
+
+// Metrics records the duration and the status code of every request by the
+// route pattern which handled the request. Requests which did not match any
+// route are recorded as "unmatched". Since errors are rendered after the
+// handler returned, the middleware needs to be used before the ErrorHandler
+// middleware
+func Metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		wrappedWriter := chiMiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(wrappedWriter, r)
+
+		route := "unmatched"
+		if routeContext := chi.RouteContext(r.Context()); routeContext != nil && routeContext.RoutePattern() != "" {
+			route = routeContext.RoutePattern()
+		}
+		status := wrappedWriter.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		metrics.ObserveRequest(r.Method, route, status, time.Since(start))
+	})
+}
+
+// RequireBearerToken only lets requests pass which send the supplied token
+// in the Authorization header. It is used for the routes which are not
+// protected by UserInfo, e.g. the metrics scraped by Prometheus
+func RequireBearerToken(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authHeaderValue := strings.TrimSpace(r.Header.Get("Authorization"))
+			if authHeaderValue == "" {
+				Fail(w, r, types.ErrorWithCode("MISSING_AUTHORIZATION_HEADER"))
+				return
+			}
+			if subtle.ConstantTimeCompare([]byte(bearerToken(authHeaderValue)), []byte(token)) != 1 {
+				Fail(w, r, types.ErrorWithCode("UNAUTHORIZED"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
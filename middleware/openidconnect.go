@@ -7,76 +7,212 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/rs/zerolog/log"
+
 	"digitales-filmmanagement-backend/config"
+	"digitales-filmmanagement-backend/devices"
 	"digitales-filmmanagement-backend/globals"
+	"digitales-filmmanagement-backend/types"
 )
 
-func UserInfo(c config.OpenIdConnectConfiguration) func(handler http.Handler) http.Handler {
+// UserInfo validates the access token sent in the Authorization header and
+// stores the identity, the groups and the roles of the user in the request
+// context. JWT access tokens are validated locally against the key set of the
+// authorization server, which avoids a request to the authorization server
+// for every API call. If a JWT access token lacks the display name or the
+// groups of the user, they are taken from the cached or requested userinfo
+// response. Opaque tokens and all tokens of authorization servers without a
+// key set are validated using the userinfo endpoint. Device keys
+// issued to the registers are accepted as well and validated against the
+// database.
+//
+// If multiple authorization servers are configured, every token is only sent
+// to a single server, which is selected by the issuer of JWT access tokens or
+// by the prefix of opaque tokens. Opaque tokens without a known prefix are
+// only sent to the server configured as fallback. Tokens which cannot be
+// assigned to any server are refused without contacting a server
+func UserInfo(configurations []*config.OpenIdConnectConfiguration) func(handler http.Handler) http.Handler {
+	providers := newIdentityProviders(configurations)
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
 			// access the current request context
 			ctx := request.Context()
-			// now get the global error handler
-			nativeErrorChannel := ctx.Value("nativeErrorChannel").(chan error)
-			handledNativeError := ctx.Value("nativeErrorHandled").(chan bool)
-			apiErrorHandler := ctx.Value("apiErrorChannel").(chan string)
-			handledApiError := ctx.Value("apiErrorHandled").(chan bool)
 			// now access the request's headers and get the authorization header value
 			authHeaderValue := strings.TrimSpace(request.Header.Get("Authorization"))
 			// now check if the header actually does contain anything
 			if authHeaderValue == "" {
-				apiErrorHandler <- "MISSING_AUTHORIZATION_HEADER"
-				<-handledApiError
+				Fail(writer, request, types.ErrorWithCode("MISSING_AUTHORIZATION_HEADER"))
 				return
 			}
-			// since some value was set in the authorization header, now build the request for the userinfo endpoint of
-			// the OpenIDConnect server
-			userinfoRequest, err := http.NewRequest("GET", *c.UserInfoEndpoint, nil)
-			if err != nil {
-				nativeErrorChannel <- err
-				<-handledNativeError
+
+			var userInfo map[string]interface{}
+			token := bearerToken(authHeaderValue)
+			if devices.IsKey(token) {
+				// since the token is a device key, the request was sent by a
+				// register and is authenticated without the authorization
+				// server
+				deviceCtx, errorCode, err := authenticateDevice(ctx, token)
+				if err != nil {
+					Fail(writer, request, err)
+					return
+				}
+				if errorCode != "" {
+					Fail(writer, request, types.ErrorWithCode(errorCode))
+					return
+				}
+				logIdentity(deviceCtx, UserID(deviceCtx), Device(deviceCtx))
+				next.ServeHTTP(writer, request.WithContext(deviceCtx))
 				return
 			}
-			userinfoRequest.Header.Set("Authorization", authHeaderValue)
-
-			// now execute the request
-			userinfoResponse, err := globals.HttpClient.Do(userinfoRequest)
-			if err != nil {
-				nativeErrorChannel <- err
-				<-handledNativeError
+			provider, verifyLocally := selectProvider(providers, token)
+			if provider == nil {
+				log.Debug().Msg("access token cannot be assigned to an authorization server")
+				Fail(writer, request, types.ErrorWithCode("UNAUTHORIZED"))
 				return
 			}
-			// now check the response code
-			switch userinfoResponse.StatusCode {
-			case 200:
-				// now parse the user info into a map
-				userInfo := make(map[string]interface{})
-				err = json.NewDecoder(userinfoResponse.Body).Decode(&userInfo)
+			if verifyLocally {
+				// since the token is a jwt of a known issuer, validate its
+				// signature, issuer, audience and expiry locally
+				var err error
+				userInfo, err = verifyToken(ctx, provider.verifier, token)
 				if err != nil {
-					nativeErrorChannel <- err
-					<-handledNativeError
+					log.Debug().Err(err).Str("provider", provider.config.DisplayName()).
+						Msg("access token failed the local validation")
+					Fail(writer, request, types.ErrorWithCode("UNAUTHORIZED"))
 					return
 				}
+				// many authorization servers only put the subject into the
+				// access token, therefore the missing name and groups are
+				// taken from the userinfo response
+				if !hasIdentityClaims(userInfo, provider.config) {
+					claims := userInfo
+					var errorCode string
+					userInfo, errorCode, err = cachedProviderUserInfo(ctx, provider, token, authHeaderValue)
+					if err != nil {
+						Fail(writer, request, err)
+						return
+					}
+					if errorCode != "" {
+						Fail(writer, request, types.ErrorWithCode(errorCode))
+						return
+					}
+					userInfo, err = mergeClaims(claims, userInfo)
+					if err != nil {
+						log.Warn().Err(err).Str("provider", provider.config.DisplayName()).
+							Msg("userinfo response does not match the access token")
+						Fail(writer, request, types.ErrorWithCode("UNAUTHORIZED"))
+						return
+					}
+				}
+			} else {
+				// since the token cannot be validated locally, ask the
+				// authorization server unless a response is still cached
+				var errorCode string
+				var err error
+				userInfo, errorCode, err = cachedProviderUserInfo(ctx, provider, token, authHeaderValue)
+				if err != nil {
+					Fail(writer, request, err)
+					return
+				}
+				if errorCode != "" {
+					Fail(writer, request, types.ErrorWithCode(errorCode))
+					return
+				}
+			}
+			c := provider.config
 
-				// now set the full name of the user and the groups into the
-				// context
-				ctx = context.WithValue(ctx, "user", userInfo["given_name"])
-				break
-			case 401:
-				apiErrorHandler <- "UNAUTHORIZED"
-				<-handledApiError
-				return
-			case 403:
-				apiErrorHandler <- "FORBIDDEN"
-				<-handledApiError
-				return
-			default:
-				nativeErrorChannel <- errors.New("unexpected response code during authentication validation")
-				<-handledNativeError
+			// now set the identity of the user and the groups into the
+			// context. the subject is the only stable identifier, the name
+			// is only used for display purposes
+			subject, _ := userInfo["sub"].(string)
+			if subject == "" {
+				Fail(writer, request, types.ErrorWithCode("UNAUTHORIZED"))
 				return
 			}
+			name := displayName(userInfo, subject, c.NameClaim)
+			subject = c.Subject(subject)
+			rememberUser(ctx, subject, name)
+			groups := extractGroups(userInfo[*c.GroupsClaim])
+			ctx = context.WithValue(ctx, "user", name)
+			ctx = context.WithValue(ctx, "userId", subject)
+			ctx = context.WithValue(ctx, "groups", groups)
+			roles := mapRoles(groups, c.Roles)
+			rememberStaffRoles(ctx, subject, roles)
+			ctx = context.WithValue(ctx, "roles", roles)
+			logIdentity(ctx, subject, nil)
 
 			next.ServeHTTP(writer, request.WithContext(ctx))
 		})
 	}
 }
+
+// requestUserInfo requests the userinfo endpoint of the OpenIDConnect server
+// using the supplied authorization header value. If the server refuses the
+// token, the code of the error which should be sent to the client is returned
+func requestUserInfo(ctx context.Context, endpoint string, authHeaderValue string) (map[string]interface{}, string, error) {
+	userinfoRequest, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	userinfoRequest.Header.Set("Authorization", authHeaderValue)
+
+	// now execute the request
+	userinfoResponse, err := globals.HttpClient.Do(userinfoRequest)
+	if err != nil {
+		return nil, "", err
+	}
+	defer userinfoResponse.Body.Close()
+
+	// now check the response code
+	switch userinfoResponse.StatusCode {
+	case 200:
+		// now parse the user info into a map
+		userInfo := make(map[string]interface{})
+		err = json.NewDecoder(userinfoResponse.Body).Decode(&userInfo)
+		if err != nil {
+			return nil, "", err
+		}
+		return userInfo, "", nil
+	case 401:
+		return nil, "UNAUTHORIZED", nil
+	case 403:
+		return nil, "FORBIDDEN", nil
+	default:
+		return nil, "", errors.New("unexpected response code during authentication validation")
+	}
+}
+
+// extractGroups converts the raw value of the groups claim into a list of
+// group names. Values which are not strings are ignored
+func extractGroups(claim interface{}) []string {
+	var groups []string
+	switch claim := claim.(type) {
+	case []interface{}:
+		for _, group := range claim {
+			if name, isString := group.(string); isString {
+				groups = append(groups, name)
+			}
+		}
+	case string:
+		groups = append(groups, claim)
+	}
+	return groups
+}
+
+// mapRoles returns the backend roles granted to the members of the supplied
+// groups
+func mapRoles(groups []string, roleMapping map[types.Role][]string) []types.Role {
+	var roles []types.Role
+	for role, roleGroups := range roleMapping {
+	groupLoop:
+		for _, group := range groups {
+			for _, roleGroup := range roleGroups {
+				if group == roleGroup {
+					roles = append(roles, role)
+					break groupLoop
+				}
+			}
+		}
+	}
+	return roles
+}
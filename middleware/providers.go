@@ -0,0 +1,179 @@
+package middleware
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+
+	"digitales-filmmanagement-backend/config"
+	"digitales-filmmanagement-backend/metrics"
+)
+
+// errMismatchingSubject is returned if the userinfo response describes
+// another subject than the validated access token
+var errMismatchingSubject = errors.New("subject of the userinfo response does not match the token")
+
+// identityProvider bundles the configuration of an authorization server with
+// the verifier for its JWT access tokens and the cache for its userinfo
+// responses
+type identityProvider struct {
+	config   *config.OpenIdConnectConfiguration
+	verifier *oidc.IDTokenVerifier
+	cache    *userInfoCache
+}
+
+// newIdentityProviders prepares the verifiers and caches of the supplied
+// authorization servers
+func newIdentityProviders(configurations []*config.OpenIdConnectConfiguration) []*identityProvider {
+	providers := make([]*identityProvider, 0, len(configurations))
+	userInfoResponses = nil
+	for _, c := range configurations {
+		provider := &identityProvider{config: c, verifier: newTokenVerifier(*c)}
+		if c.CacheTTL() > 0 {
+			provider.cache = newUserInfoCache(c.CacheTTL(), *c.UserInfoCacheSize)
+			userInfoResponses = append(userInfoResponses, provider.cache)
+		}
+		providers = append(providers, provider)
+	}
+	return providers
+}
+
+// selectProvider returns the only authorization server the token may be sent
+// to, since a bearer token is never forwarded to another issuer. JWTs are
+// assigned to the server matching their unverified "iss" claim, which is only
+// used to select the server and the key set. Opaque tokens and JWTs of
+// unknown issuers are assigned to the server whose token prefix they start
+// with. Every other token is only accepted by the single configured server or
+// by the server configured as fallback for opaque tokens. If no server may
+// receive the token, nil is returned.
+//
+// If the token needs to be validated locally using the key set of the server,
+// verifyLocally is set
+func selectProvider(providers []*identityProvider, token string) (provider *identityProvider, verifyLocally bool) {
+	if isJWT(token) {
+		if issuer := readUnverifiedClaims(token).Issuer; issuer != "" {
+			for _, provider := range providers {
+				if provider.config.Issuer != nil && *provider.config.Issuer == issuer {
+					return provider, provider.verifier != nil
+				}
+			}
+		}
+	}
+	for _, provider := range providers {
+		if provider.config.TokenPrefix != nil && strings.HasPrefix(token, *provider.config.TokenPrefix) {
+			return provider, false
+		}
+	}
+	if len(providers) == 1 {
+		return providers[0], false
+	}
+	for _, provider := range providers {
+		if provider.config.IsOpaqueTokenFallback() {
+			return provider, false
+		}
+	}
+	return nil, false
+}
+
+// unverifiedClaims contains the claims of a JWT which are read without
+// validating the token
+type unverifiedClaims struct {
+	// Issuer contains the "iss" claim
+	Issuer string `json:"iss"`
+	// Expiry contains the "exp" claim as unix timestamp
+	Expiry float64 `json:"exp"`
+}
+
+// readUnverifiedClaims reads the issuer and the expiry from the payload of
+// the JWT without validating the token. If the token is not a JWT, empty
+// claims are returned
+func readUnverifiedClaims(token string) unverifiedClaims {
+	var claims unverifiedClaims
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return claims
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return claims
+	}
+	if err = json.Unmarshal(payload, &claims); err != nil {
+		return unverifiedClaims{}
+	}
+	return claims
+}
+
+// requestProviderUserInfo asks the userinfo endpoint of the authorization
+// server for the identity of the token owner. If the server refuses the
+// token, the code of the error which should be sent to the client is returned
+func requestProviderUserInfo(ctx context.Context, provider *identityProvider, authHeaderValue string) (map[string]interface{}, string, error) {
+	start := time.Now()
+	userInfo, errorCode, err := requestUserInfo(ctx, *provider.config.UserInfoEndpoint, authHeaderValue)
+	metrics.ObserveUserInfoRequest(provider.config.DisplayName(), userInfoResult(errorCode, err), time.Since(start))
+	return userInfo, errorCode, err
+}
+
+// cachedProviderUserInfo returns the cached userinfo response for the token
+// or requests it from the authorization server. Successful responses are
+// stored in the cache of the server
+func cachedProviderUserInfo(ctx context.Context, provider *identityProvider, token string, authHeaderValue string) (map[string]interface{}, string, error) {
+	if provider.cache != nil {
+		if userInfo, cached := provider.cache.get(token); cached {
+			return userInfo, "", nil
+		}
+	}
+	userInfo, errorCode, err := requestProviderUserInfo(ctx, provider, authHeaderValue)
+	if err != nil || errorCode != "" {
+		return nil, errorCode, err
+	}
+	if provider.cache != nil {
+		provider.cache.put(token, userInfo)
+	}
+	return userInfo, "", nil
+}
+
+// hasIdentityClaims reports if the claims of the access token contain the
+// display name and the groups of the user
+func hasIdentityClaims(claims map[string]interface{}, c *config.OpenIdConnectConfiguration) bool {
+	_, hasGroups := claims[*c.GroupsClaim]
+	return hasGroups && displayName(claims, "", c.NameClaim) != ""
+}
+
+// mergeClaims adds the claims of the userinfo response which are missing in
+// the validated access token. The claims of the token take precedence. Since
+// the token has been validated locally, the userinfo response needs to
+// describe the same subject
+func mergeClaims(tokenClaims map[string]interface{}, userInfo map[string]interface{}) (map[string]interface{}, error) {
+	tokenSubject, _ := tokenClaims["sub"].(string)
+	userInfoSubject, _ := userInfo["sub"].(string)
+	if tokenSubject != userInfoSubject {
+		return nil, errMismatchingSubject
+	}
+	merged := make(map[string]interface{}, len(userInfo)+len(tokenClaims))
+	for claim, value := range userInfo {
+		merged[claim] = value
+	}
+	for claim, value := range tokenClaims {
+		merged[claim] = value
+	}
+	return merged, nil
+}
+
+// userInfoResult describes the outcome of a userinfo request in the metrics
+func userInfoResult(errorCode string, err error) string {
+	switch {
+	case err != nil:
+		return "error"
+	case errorCode == "UNAUTHORIZED":
+		return "unauthorized"
+	case errorCode == "FORBIDDEN":
+		return "forbidden"
+	default:
+		return "success"
+	}
+}
@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracing records every request as span and continues the trace of the
+// client if the request contains a W3C trace context. The span is named
+// after the route pattern which handled the request once the routing is
+// done. Since errors are rendered after the handler returned, the middleware
+// needs to be used before the ErrorHandler middleware
+func Tracing(next http.Handler) http.Handler {
+	namedHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+		routeContext := chi.RouteContext(r.Context())
+		if routeContext == nil || routeContext.RoutePattern() == "" {
+			return
+		}
+		span := trace.SpanFromContext(r.Context())
+		span.SetName(r.Method + " " + routeContext.RoutePattern())
+		span.SetAttributes(semconv.HTTPRoute(routeContext.RoutePattern()))
+	})
+	return otelhttp.NewHandler(namedHandler, "http request")
+}
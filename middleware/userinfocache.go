@@ -0,0 +1,126 @@
+package middleware
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"digitales-filmmanagement-backend/types"
+)
+
+// userInfoCache is a bounded in-memory cache for userinfo responses. The
+// entries are keyed by the SHA-256 hash of the token, so the cache never
+// holds the tokens themselves. If the cache is full, the least recently used
+// entry is removed
+type userInfoCache struct {
+	mutex   sync.Mutex
+	ttl     time.Duration
+	size    int
+	entries map[[sha256.Size]byte]*list.Element
+	order   *list.List
+	hits    atomic.Uint64
+	misses  atomic.Uint64
+}
+
+// userInfoCacheEntry contains a cached userinfo response
+type userInfoCacheEntry struct {
+	key      [sha256.Size]byte
+	userInfo map[string]interface{}
+	expires  time.Time
+}
+
+// userInfoResponses contains the caches of every authorization server used
+// by the UserInfo middleware. They are kept in a package variable to allow
+// the monitoring of the caches
+var userInfoResponses []*userInfoCache
+
+func newUserInfoCache(ttl time.Duration, size int) *userInfoCache {
+	return &userInfoCache{
+		ttl:     ttl,
+		size:    size,
+		entries: make(map[[sha256.Size]byte]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// get returns the cached userinfo response for the token if it has not
+// expired yet
+func (c *userInfoCache) get(token string) (map[string]interface{}, bool) {
+	key := sha256.Sum256([]byte(token))
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	element, found := c.entries[key]
+	if !found {
+		c.misses.Add(1)
+		return nil, false
+	}
+	entry := element.Value.(*userInfoCacheEntry)
+	if time.Now().After(entry.expires) {
+		c.order.Remove(element)
+		delete(c.entries, key)
+		c.misses.Add(1)
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	c.hits.Add(1)
+	return entry.userInfo, true
+}
+
+// put caches the userinfo response for the configured duration. If the
+// expiry of the token is known from the response or from the unverified
+// claims of a JWT, the entry expires with the token at the latest. A revoked
+// token is accepted until its entry expires, therefore the configured
+// duration is the longest time a revocation may go unnoticed
+func (c *userInfoCache) put(token string, userInfo map[string]interface{}) {
+	expires := time.Now().Add(c.ttl)
+	for _, exp := range []float64{readUnverifiedClaims(token).Expiry, numericClaim(userInfo["exp"])} {
+		if exp <= 0 {
+			continue
+		}
+		if tokenExpiry := time.Unix(int64(exp), 0); tokenExpiry.Before(expires) {
+			expires = tokenExpiry
+		}
+	}
+	if !expires.After(time.Now()) {
+		return
+	}
+
+	key := sha256.Sum256([]byte(token))
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if element, found := c.entries[key]; found {
+		element.Value = &userInfoCacheEntry{key: key, userInfo: userInfo, expires: expires}
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&userInfoCacheEntry{key: key, userInfo: userInfo, expires: expires})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*userInfoCacheEntry).key)
+	}
+}
+
+// numericClaim returns the value of a numeric claim or zero if the claim is
+// missing or not a number
+func numericClaim(claim interface{}) float64 {
+	value, _ := claim.(float64)
+	return value
+}
+
+// UserInfoCacheStatistics returns the number of hits, misses and entries of
+// the userinfo caches of every authorization server. If the caches are
+// disabled, empty statistics are returned
+func UserInfoCacheStatistics() types.CacheStatistics {
+	var statistics types.CacheStatistics
+	for _, cache := range userInfoResponses {
+		cache.mutex.Lock()
+		statistics.Entries += cache.order.Len()
+		cache.mutex.Unlock()
+		statistics.Hits += cache.hits.Load()
+		statistics.Misses += cache.misses.Load()
+	}
+	return statistics
+}
@@ -12,9 +12,10 @@ FROM
 
 -- name: insert-transaction
 INSERT INTO
-    cinema_management.transactions(title, description, amount, by, register)
+    cinema_management.transactions(title, description, amount, by, register, device, by_subject)
 VALUES
-    ($1, $2, $3, $4, $5::uuid);
+    ($1, $2, $3, $4, $5::uuid, $6::uuid, $7)
+RETURNING id;
 
 -- name: insert-article-sale
 INSERT INTO
@@ -28,4 +29,377 @@ SELECT
 FROM cinema_management.article_sales
 WHERE time BETWEEN $1 AND $2
 GROUP BY name
-ORDER BY name;
\ No newline at end of file
+ORDER BY name;
+
+-- name: get-venues
+SELECT
+    *
+FROM
+    cinema_management.venues
+ORDER BY name;
+
+-- name: insert-venue
+INSERT INTO
+    cinema_management.venues(name, capacity)
+VALUES
+    ($1, $2)
+RETURNING id;
+
+-- name: get-screenings
+SELECT
+    s.id, s.title, s.starts_at, s.venue, s.capacity, s.wp_post_id,
+    coalesce(s.capacity, v.capacity) AS effective_capacity,
+    (SELECT count(*) FROM cinema_management.tickets t WHERE t.screening = s.id) AS sold_tickets,
+    (SELECT coalesce(sum(r.seats), 0) FROM cinema_management.reservations r
+     WHERE r.screening = s.id AND r.status = 'held') AS reserved_seats
+FROM cinema_management.screenings s
+JOIN cinema_management.venues v ON v.id = s.venue
+WHERE s.starts_at >= $1
+ORDER BY s.starts_at;
+
+-- name: insert-screening
+INSERT INTO
+    cinema_management.screenings(title, starts_at, venue, capacity, wp_post_id)
+VALUES
+    ($1, $2, $3::uuid, $4, $5)
+RETURNING id;
+
+-- name: lock-screening-capacity
+SELECT
+    s.title, s.starts_at, coalesce(s.capacity, v.capacity) AS effective_capacity
+FROM cinema_management.screenings s
+JOIN cinema_management.venues v ON v.id = s.venue
+WHERE s.id = $1::uuid
+FOR UPDATE OF s;
+
+-- name: count-occupied-seats
+SELECT
+    (SELECT count(*) FROM cinema_management.tickets WHERE screening = $1::uuid) +
+    (SELECT coalesce(sum(seats), 0) FROM cinema_management.reservations
+     WHERE screening = $1::uuid AND status = 'held');
+
+-- name: insert-ticket
+INSERT INTO
+    cinema_management.tickets(screening, transaction, sold_by, capacity_override)
+VALUES
+    ($1::uuid, $2::uuid, $3, $4)
+RETURNING id;
+
+-- name: get-reservations
+SELECT
+    id, screening, name, email, seats, status, created_at, transaction
+FROM cinema_management.reservations
+WHERE screening = $1::uuid
+ORDER BY created_at;
+
+-- name: insert-reservation
+INSERT INTO
+    cinema_management.reservations(screening, name, email, seats)
+VALUES
+    ($1::uuid, $2, $3, $4)
+RETURNING id, status, created_at;
+
+-- name: lock-reservation
+SELECT
+    seats, status
+FROM cinema_management.reservations
+WHERE id = $1::uuid AND screening = $2::uuid
+FOR UPDATE;
+
+-- name: claim-reservation
+UPDATE cinema_management.reservations
+SET status = 'claimed', transaction = $2::uuid
+WHERE id = $1::uuid;
+
+-- name: cancel-reservation
+UPDATE cinema_management.reservations
+SET status = 'cancelled'
+WHERE id = $1::uuid;
+
+-- name: release-expired-reservations
+UPDATE cinema_management.reservations r
+SET status = 'released'
+FROM cinema_management.screenings s
+WHERE r.screening = s.id
+  AND r.status = 'held'
+  AND s.starts_at - make_interval(secs => $1) <= now();
+
+-- name: get-ticket-check-in
+SELECT
+    screening, checked_in_at IS NOT NULL AS checked_in
+FROM cinema_management.tickets
+WHERE id = $1::uuid;
+
+-- name: get-reservation-admissions
+SELECT
+    r.status, count(t.checked_in_at)
+FROM cinema_management.reservations r
+LEFT JOIN cinema_management.tickets t ON t.transaction = r.transaction
+WHERE r.id = $1::uuid
+GROUP BY r.status;
+
+-- name: check-in-ticket
+UPDATE cinema_management.tickets
+SET checked_in_at = now()
+WHERE id = $1::uuid AND checked_in_at IS NULL
+RETURNING screening;
+
+-- name: get-reservation-check-in
+SELECT
+    screening, status
+FROM cinema_management.reservations
+WHERE id = $1::uuid;
+
+-- name: check-in-reservation
+UPDATE cinema_management.tickets t
+SET checked_in_at = now()
+FROM cinema_management.reservations r
+WHERE r.id = $1::uuid
+  AND r.status = 'claimed'
+  AND t.transaction = r.transaction
+  AND t.checked_in_at IS NULL
+RETURNING t.screening;
+
+-- name: count-check-ins
+SELECT
+    count(*) FILTER (WHERE checked_in_at IS NOT NULL) AS checked_in,
+    count(*) AS sold_tickets
+FROM cinema_management.tickets
+WHERE screening = $1::uuid;
+
+-- name: get-ticket-screening
+SELECT
+    screening
+FROM cinema_management.tickets
+WHERE id = $1::uuid;
+
+-- name: get-unsynced-attendance
+WITH totals AS (
+    SELECT
+        s.id AS screening, s.wp_post_id,
+        (SELECT count(*) FROM cinema_management.tickets t WHERE t.screening = s.id) AS attendance,
+        (SELECT coalesce(sum(tr.amount), 0) FROM cinema_management.transactions tr
+         WHERE tr.id IN (SELECT t.transaction FROM cinema_management.tickets t WHERE t.screening = s.id)) AS revenue
+    FROM cinema_management.screenings s
+    WHERE s.wp_post_id IS NOT NULL
+      AND s.starts_at <= now() - make_interval(secs => $1)
+)
+SELECT
+    totals.screening, totals.wp_post_id, totals.attendance, totals.revenue
+FROM totals
+LEFT JOIN cinema_management.wordpress_sync ws ON ws.screening = totals.screening
+WHERE ws.screening IS NULL
+   OR ws.wp_post_id <> totals.wp_post_id
+   OR ws.attendance <> totals.attendance
+   OR ws.revenue <> totals.revenue;
+
+-- name: upsert-wordpress-sync
+INSERT INTO
+    cinema_management.wordpress_sync(screening, wp_post_id, attendance, revenue)
+VALUES
+    ($1::uuid, $2, $3, $4)
+ON CONFLICT (screening) DO UPDATE
+SET wp_post_id = excluded.wp_post_id,
+    attendance = excluded.attendance,
+    revenue    = excluded.revenue,
+    synced_at  = now();
+
+-- name: get-shifts
+SELECT
+    id, screening, role, required
+FROM cinema_management.shifts
+WHERE screening = $1::uuid
+ORDER BY role;
+
+-- name: get-shift-signups
+SELECT
+    su.shift, su.volunteer, su.volunteer_name, su.signed_up_at
+FROM cinema_management.shift_signups su
+JOIN cinema_management.shifts sh ON sh.id = su.shift
+WHERE sh.screening = $1::uuid
+ORDER BY su.signed_up_at;
+
+-- name: lock-screening
+SELECT
+    id
+FROM cinema_management.screenings
+WHERE id = $1::uuid
+FOR UPDATE;
+
+-- name: upsert-shift
+INSERT INTO
+    cinema_management.shifts(screening, role, required)
+VALUES
+    ($1::uuid, $2, $3)
+ON CONFLICT (screening, role) DO UPDATE
+SET required = excluded.required;
+
+-- name: count-signups-of-removed-shifts
+SELECT
+    count(*)
+FROM cinema_management.shift_signups su
+JOIN cinema_management.shifts sh ON sh.id = su.shift
+WHERE sh.screening = $1::uuid AND NOT (sh.role = ANY ($2::text[]));
+
+-- name: delete-removed-shifts
+DELETE FROM cinema_management.shifts
+WHERE screening = $1::uuid AND NOT (role = ANY ($2::text[]));
+
+-- name: lock-shift
+SELECT
+    required,
+    (SELECT count(*) FROM cinema_management.shift_signups su WHERE su.shift = sh.id) AS signed_up
+FROM cinema_management.shifts sh
+WHERE sh.id = $1::uuid AND sh.screening = $2::uuid
+FOR UPDATE;
+
+-- name: insert-shift-signup
+INSERT INTO
+    cinema_management.shift_signups(shift, volunteer, volunteer_name)
+VALUES
+    ($1::uuid, $2, $3)
+ON CONFLICT DO NOTHING;
+
+-- name: delete-shift-signup
+DELETE FROM cinema_management.shift_signups
+WHERE shift = $1::uuid AND volunteer = $2;
+
+-- name: get-understaffed-shifts
+SELECT
+    s.id AS screening, s.title, s.starts_at, sh.id AS shift, sh.role, sh.required,
+    count(su.volunteer) AS signed_up
+FROM cinema_management.shifts sh
+JOIN cinema_management.screenings s ON s.id = sh.screening
+LEFT JOIN cinema_management.shift_signups su ON su.shift = sh.id
+WHERE s.starts_at >= $1
+GROUP BY s.id, sh.id
+HAVING count(su.volunteer) < sh.required
+ORDER BY s.starts_at, sh.role;
+
+-- name: get-devices
+SELECT
+    id, name, register, roles, created_by, created_at, revoked_at, unlocked_by_name, unlocked_at
+FROM cinema_management.devices
+ORDER BY created_at;
+
+-- name: insert-device
+INSERT INTO
+    cinema_management.devices(name, register, roles, key_hash, created_by)
+VALUES
+    ($1, $2::uuid, $3, $4, $5)
+RETURNING id, created_at;
+
+-- name: revoke-device
+UPDATE cinema_management.devices
+SET revoked_at = now(), unlocked_by = NULL, unlocked_by_name = NULL, unlocked_at = NULL
+WHERE id = $1::uuid AND revoked_at IS NULL;
+
+-- name: get-device-by-key
+SELECT
+    d.id, d.register, d.roles, d.unlocked_by, d.unlocked_by_name, coalesce(sp.roles, '{}')
+FROM cinema_management.devices d
+LEFT JOIN cinema_management.staff_pins sp ON sp.subject = d.unlocked_by
+WHERE d.key_hash = $1 AND d.revoked_at IS NULL;
+
+-- name: unlock-device
+UPDATE cinema_management.devices
+SET unlocked_by = $2, unlocked_by_name = $3, unlocked_at = now(), failed_pin_attempts = 0
+WHERE id = $1::uuid AND revoked_at IS NULL;
+
+-- name: lock-device-pin-attempts
+SELECT
+    coalesce(pin_locked_until > now(), false)
+FROM cinema_management.devices
+WHERE id = $1::uuid
+FOR UPDATE;
+
+-- name: record-failed-pin-attempt
+UPDATE cinema_management.devices
+SET failed_pin_attempts = CASE WHEN failed_pin_attempts + 1 >= $2 THEN 0 ELSE failed_pin_attempts + 1 END,
+    pin_locked_until    = CASE WHEN failed_pin_attempts + 1 >= $2 THEN now() + make_interval(secs => $3) ELSE pin_locked_until END
+WHERE id = $1::uuid;
+
+-- name: lock-device-active-staff
+SELECT
+    unlocked_by, unlocked_by_name
+FROM cinema_management.devices
+WHERE id = $1::uuid
+FOR UPDATE;
+
+-- name: lock-device
+UPDATE cinema_management.devices
+SET unlocked_by = NULL, unlocked_by_name = NULL, unlocked_at = NULL
+WHERE id = $1::uuid;
+
+-- name: upsert-staff-pin
+INSERT INTO
+    cinema_management.staff_pins(subject, name, pin_hash, roles)
+VALUES
+    ($1, $2, $3, $4)
+ON CONFLICT (subject) DO UPDATE
+SET name = excluded.name, pin_hash = excluded.pin_hash, roles = excluded.roles, updated_at = now(),
+    failed_pin_attempts = 0, pin_locked_until = NULL;
+
+-- name: get-staff-with-pin
+SELECT
+    subject, name
+FROM cinema_management.staff_pins
+ORDER BY name;
+
+-- name: lock-staff-pin
+SELECT
+    name, pin_hash, coalesce(pin_locked_until > now(), false), roles
+FROM cinema_management.staff_pins
+WHERE subject = $1
+FOR UPDATE;
+
+-- name: record-failed-staff-pin-attempt
+UPDATE cinema_management.staff_pins
+SET failed_pin_attempts = CASE WHEN failed_pin_attempts + 1 >= $2 THEN 0 ELSE failed_pin_attempts + 1 END,
+    pin_locked_until    = CASE WHEN failed_pin_attempts + 1 >= $2 THEN now() + make_interval(secs => $3) ELSE pin_locked_until END
+WHERE subject = $1;
+
+-- name: reset-staff-pin-attempts
+UPDATE cinema_management.staff_pins
+SET failed_pin_attempts = 0
+WHERE subject = $1;
+
+-- name: update-staff-pin-roles
+UPDATE cinema_management.staff_pins
+SET roles = $2
+WHERE subject = $1 AND roles <> $2;
+
+-- name: upsert-user
+INSERT INTO
+    cinema_management.users(subject, name)
+VALUES
+    ($1, $2)
+ON CONFLICT (subject) DO UPDATE
+SET name = excluded.name, last_seen_at = now();
+
+-- name: get-transactions
+SELECT
+    t.id, t.title, t.description, coalesce(t.amount, 0) AS amount, coalesce(u.name, t.by) AS by, t.by_subject, t.register, t.device,
+    t.created_at
+FROM cinema_management.transactions t
+LEFT JOIN cinema_management.users u ON u.subject = t.by_subject
+WHERE t.created_at BETWEEN $1 AND $2
+ORDER BY t.created_at;
+
+-- name: insert-audit-entry
+INSERT INTO
+    cinema_management.audit_log(actor, actor_name, device, request_id, action, entity, entity_id, before, after)
+VALUES
+    ($1, $2, $3::uuid, $4, $5, $6, $7, $8::jsonb, $9::jsonb);
+
+-- name: get-audit-entries
+SELECT
+    id, occurred_at, actor, actor_name, device, request_id, action, entity, entity_id, before, after
+FROM cinema_management.audit_log
+WHERE occurred_at BETWEEN $1 AND $2
+  AND ($3::text IS NULL OR entity = $3)
+  AND ($4::text IS NULL OR entity_id = $4)
+  AND ($5::text IS NULL OR actor = $5)
+  AND ($6::text IS NULL OR action = $6)
+ORDER BY id DESC
+LIMIT $7;
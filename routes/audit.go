@@ -0,0 +1,80 @@
+package routes
+
+import (
+	"digitales-filmmanagement-backend/globals"
+	"digitales-filmmanagement-backend/middleware"
+	"digitales-filmmanagement-backend/types"
+	"encoding/json"
+	"github.com/ggicci/httpin"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+)
+
+// defaultAuditLimit contains the number of audit entries returned if the
+// request does not contain a limit
+const defaultAuditLimit = 100
+
+// maximalAuditLimit contains the maximal number of audit entries returned by
+// a single request
+const maximalAuditLimit = 1000
+
+func AuditRouter() http.Handler {
+	r := chi.NewRouter()
+	r.Use(middleware.RequireRole(types.RoleAdmin))
+	r.With(httpin.NewInput(types.AuditRequestInput{})).
+		Get("/", middleware.Handle(getAuditEntries))
+	return r
+}
+
+// getAuditEntries returns the newest entries of the audit log matching the
+// filters of the request. If no period is supplied, the entries of the last
+// 24 hours are returned
+func getAuditEntries(w http.ResponseWriter, r *http.Request) error {
+	// access the request context
+	ctx := r.Context()
+
+	parameters := ctx.Value(httpin.Input).(*types.AuditRequestInput)
+	from, until := statisticsPeriod(&types.StatisticsRequestInput{From: parameters.From, Until: parameters.Until})
+	limit := defaultAuditLimit
+	if parameters.Limit != nil {
+		limit = *parameters.Limit
+	}
+	if limit < 1 || limit > maximalAuditLimit {
+		return types.ErrorWithCode("INVALID_AUDIT_LIMIT")
+	}
+
+	rows, err := globals.SqlQueries.QueryContext(ctx, globals.Database, "get-audit-entries", from, until,
+		parameters.Entity, parameters.EntityID, parameters.Actor, parameters.Action, limit)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	// the states are stored as jsonb and are passed through without decoding
+	// them, therefore the rows are scanned manually
+	var entries []types.AuditEntry
+	for rows.Next() {
+		var entry types.AuditEntry
+		var before, after []byte
+		err = rows.Scan(&entry.ID, &entry.OccurredAt, &entry.Actor, &entry.ActorName, &entry.Device,
+			&entry.RequestID, &entry.Action, &entry.Entity, &entry.EntityID, &before, &after)
+		if err != nil {
+			return err
+		}
+		entry.Before = before
+		entry.After = after
+		entries = append(entries, entry)
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "text/json")
+	err = json.NewEncoder(w).Encode(entries)
+	if err != nil {
+		return err
+	}
+	return nil
+}
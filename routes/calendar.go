@@ -0,0 +1,163 @@
+package routes
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"digitales-filmmanagement-backend/calendar"
+	"digitales-filmmanagement-backend/globals"
+	"digitales-filmmanagement-backend/middleware"
+	"digitales-filmmanagement-backend/types"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/blockloop/scan/v2"
+	"github.com/ggicci/httpin"
+	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// wordpressTimeLayout is the layout used by WordPress for the date columns
+const wordpressTimeLayout = "2006-01-02 15:04:05"
+
+// startTimeLayouts contains the layouts accepted for the start of a screening
+// if it is not stored as unix timestamp
+var startTimeLayouts = []string{wordpressTimeLayout, "2006-01-02 15:04", "2006-01-02T15:04:05", "2006-01-02T15:04"}
+
+// errInvalidStart is returned if the start of a screening cannot be parsed
+var errInvalidStart = errors.New("invalid screening start")
+
+func CalendarRouter() http.Handler {
+	r := chi.NewRouter()
+	r.With(httpin.NewInput(types.CalendarRequestInput{})).
+		Get("/screenings.ics", middleware.Handle(screeningCalendar))
+	return r
+}
+
+// screeningCalendar renders the screenings published on the WordPress website
+// as iCalendar feed. The feed may be filtered by the venue and the category
+// of the screenings. Since calendar clients poll the feed regularly, the
+// response carries an ETag derived from the rendered feed, allowing the
+// clients to revalidate their copy without downloading it again. No
+// Last-Modified header is sent, since removed screenings and changed meta
+// values do not advance the modification time of the posts and clients
+// revalidating by date would keep outdated events
+func screeningCalendar(w http.ResponseWriter, r *http.Request) error {
+	// access the request context
+	ctx := r.Context()
+	parameters := ctx.Value(httpin.Input).(*types.CalendarRequestInput)
+
+	screenings, err := getWordPressScreenings(r, parameters)
+	if err != nil {
+		return err
+	}
+
+	c := globals.Configuration.Calendar
+	earliestStart := time.Now().Add(-c.HistoryDuration())
+	var events []calendar.Event
+	for _, screening := range screenings {
+		start, err := parseScreeningStart(screening.Start)
+		if err != nil {
+			log.Warn().Err(err).Int64("post", screening.PostID).Str("start", screening.Start).
+				Msg("skipping screening with invalid start in calendar")
+			continue
+		}
+		if start.Before(earliestStart) {
+			continue
+		}
+		modified, err := time.ParseInLocation(wordpressTimeLayout, screening.Modified, time.UTC)
+		if err != nil {
+			modified = time.Unix(0, 0)
+		}
+
+		event := calendar.Event{
+			UID:      fmt.Sprintf("screening-%d@%s", screening.PostID, *c.UidDomain),
+			Summary:  screening.Title,
+			Start:    start,
+			End:      start.Add(c.Duration()),
+			Modified: modified,
+		}
+		event.Description = strings.TrimSpace(screening.Excerpt)
+		if screening.Venue != nil {
+			event.Location = *screening.Venue
+		}
+		if screening.Categories != nil && *screening.Categories != "" {
+			event.Categories = strings.Split(*screening.Categories, "\n")
+		}
+		events = append(events, event)
+	}
+
+	var body bytes.Buffer
+	if err = calendar.Write(&body, "Programm", events); err != nil {
+		return err
+	}
+	checksum := sha256.Sum256(body.Bytes())
+
+	// now let the http package answer conditional requests using the ETag.
+	// the zero modification time prevents the Last-Modified header
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("ETag", `"`+hex.EncodeToString(checksum[:16])+`"`)
+	http.ServeContent(w, r, "screenings.ics", time.Time{}, bytes.NewReader(body.Bytes()))
+	return nil
+}
+
+// getWordPressScreenings reads the published screenings from the WordPress
+// database using the configured post layout and filters
+func getWordPressScreenings(r *http.Request, parameters *types.CalendarRequestInput) ([]types.WordPressScreening, error) {
+	wp := globals.Configuration.WordPress
+	query := fmt.Sprintf(`SELECT p.ID, p.post_title, p.post_excerpt, p.post_modified_gmt,
+       start.meta_value AS start, venue.meta_value AS venue,
+       (SELECT GROUP_CONCAT(t.name ORDER BY t.name SEPARATOR '\n')
+        FROM %[3]s tr
+        JOIN %[4]s tt ON tt.term_taxonomy_id = tr.term_taxonomy_id
+        JOIN %[5]s t ON t.term_id = tt.term_id
+        WHERE tr.object_id = p.ID AND tt.taxonomy = ?) AS categories
+FROM %[1]s p
+JOIN %[2]s start ON start.post_id = p.ID AND start.meta_key = ?
+LEFT JOIN %[2]s venue ON venue.post_id = p.ID AND venue.meta_key = ?
+WHERE p.post_type = ? AND p.post_status = 'publish'`,
+		wp.Table("posts"), wp.Table("postmeta"), wp.Table("term_relationships"),
+		wp.Table("term_taxonomy"), wp.Table("terms"))
+	arguments := []interface{}{*wp.CategoryTaxonomy, *wp.StartMetaKey, *wp.VenueMetaKey, *wp.ScreeningPostType}
+
+	if parameters.Venue != nil && *parameters.Venue != "" {
+		query += " AND venue.meta_value = ?"
+		arguments = append(arguments, *parameters.Venue)
+	}
+	if parameters.Category != nil && *parameters.Category != "" {
+		query += fmt.Sprintf(` AND EXISTS (SELECT 1 FROM %[1]s tr
+        JOIN %[2]s tt ON tt.term_taxonomy_id = tr.term_taxonomy_id
+        JOIN %[3]s t ON t.term_id = tt.term_id
+        WHERE tr.object_id = p.ID AND tt.taxonomy = ? AND t.slug = ?)`,
+			wp.Table("term_relationships"), wp.Table("term_taxonomy"), wp.Table("terms"))
+		arguments = append(arguments, *wp.CategoryTaxonomy, *parameters.Category)
+	}
+	query += " ORDER BY p.ID"
+
+	rows, err := globals.WpDatabase.QueryContext(r.Context(), query, arguments...)
+	if err != nil {
+		return nil, err
+	}
+	var screenings []types.WordPressScreening
+	err = scan.Rows(&screenings, rows)
+	return screenings, err
+}
+
+// parseScreeningStart parses the start of a screening stored either as unix
+// timestamp or as local time
+func parseScreeningStart(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if timestamp, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(timestamp, 0), nil
+	}
+	for _, layout := range startTimeLayouts {
+		if start, err := time.ParseInLocation(layout, value, calendar.Location); err == nil {
+			return start, nil
+		}
+	}
+	return time.Time{}, errInvalidStart
+}
@@ -0,0 +1,323 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"digitales-filmmanagement-backend/audit"
+	"digitales-filmmanagement-backend/globals"
+	"digitales-filmmanagement-backend/middleware"
+	"digitales-filmmanagement-backend/tickets"
+	"digitales-filmmanagement-backend/types"
+	"encoding/json"
+	"errors"
+	"github.com/blockloop/scan/v2"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"net/http"
+)
+
+// qrCodeSize contains the width and height of the rendered PNG QR codes
+const qrCodeSize = 512
+
+// TicketsRouter serves the QR codes of the tickets. The codes are only
+// available if a signing key for the ticket tokens is configured
+func TicketsRouter() http.Handler {
+	r := chi.NewRouter()
+	r.Use(middleware.RequireRole(types.RoleDoor))
+	if globals.Configuration.Tickets.IsEnabled() {
+		r.Get("/{ticketId}/qr.{format}", middleware.Handle(ticketQRCode))
+	}
+	return r
+}
+
+// CheckInsRouter serves the check-in of the scanned tickets. The check-in is
+// only available if a signing key for the ticket tokens is configured
+func CheckInsRouter() http.Handler {
+	r := chi.NewRouter()
+	r.Use(middleware.RequireRole(types.RoleDoor))
+	if globals.Configuration.Tickets.IsEnabled() {
+		r.Post("/", middleware.Handle(checkIn))
+	}
+	return r
+}
+
+func ticketQRCode(w http.ResponseWriter, r *http.Request) error {
+	ticketId := chi.URLParam(r, "ticketId")
+	if _, err := uuid.Parse(ticketId); err != nil {
+		return types.ErrorWithCode("INVALID_TICKET_UUID")
+	}
+	// now check that the ticket exists before issuing a token for it
+	row, err := globals.SqlQueries.QueryRowContext(r.Context(), globals.Database, "get-ticket-screening", ticketId)
+	if err != nil {
+		return err
+	}
+	var screeningId string
+	err = row.Scan(&screeningId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return types.ErrorWithCode("TICKET_NOT_FOUND")
+	}
+	if err != nil {
+		return err
+	}
+	return writeQRCode(w, r, tickets.Sign(tickets.KindTicket, ticketId))
+}
+
+func reservationQRCode(w http.ResponseWriter, r *http.Request) error {
+	screeningId, reservationId, valid := reservationParameters(r)
+	if !valid {
+		return types.ErrorWithCode("INVALID_RESERVATION_UUID")
+	}
+	// now check that the reservation exists for the screening before issuing
+	// a token for it
+	row, err := globals.SqlQueries.QueryRowContext(r.Context(), globals.Database, "get-reservation-check-in", reservationId)
+	if err != nil {
+		return err
+	}
+	var reservationScreening, status string
+	err = row.Scan(&reservationScreening, &status)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && reservationScreening != screeningId) {
+		return types.ErrorWithCode("RESERVATION_NOT_FOUND")
+	}
+	if err != nil {
+		return err
+	}
+	return writeQRCode(w, r, tickets.Sign(tickets.KindReservation, reservationId))
+}
+
+// writeQRCode renders the token as QR code in the format requested in the
+// path of the request. The code is rendered completely before the response
+// is written, therefore a rendering error is still sent as error response
+func writeQRCode(w http.ResponseWriter, r *http.Request, token string) error {
+	var code bytes.Buffer
+	var contentType string
+	var err error
+	switch chi.URLParam(r, "format") {
+	case "png":
+		contentType = "image/png"
+		err = tickets.WritePNG(&code, token, qrCodeSize)
+	case "svg":
+		contentType = "image/svg+xml"
+		err = tickets.WriteSVG(&code, token)
+	default:
+		return types.ErrorWithCode("UNSUPPORTED_QR_CODE_FORMAT")
+	}
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", contentType)
+	if _, err = code.WriteTo(w); err != nil {
+		log.Error().Err(err).Msg("unable to send qr code")
+	}
+	return nil
+}
+
+// checkIn validates the token scanned by a door device and marks the ticket
+// or the tickets of a claimed reservation as used. The tickets are marked
+// with a single conditional update, therefore two devices scanning the same
+// code at the same time cannot admit the guest twice
+func checkIn(w http.ResponseWriter, r *http.Request) error {
+	// access the request context
+	ctx := r.Context()
+
+	var request types.CheckInRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		log.Warn().Err(err).Str("error", "INVALID_JSON").Msg("received invalid json payload")
+		return types.ErrorWithCode("INVALID_JSON")
+	}
+	kind, id, err := tickets.Verify(request.Token)
+	if err != nil {
+		log.Warn().Err(err).Msg("received ticket token with invalid signature")
+		return types.ErrorWithCode("INVALID_TICKET_TOKEN")
+	}
+
+	tx, err := globals.Database.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	// the rollback is a no-op if the transaction has been committed already
+	defer tx.Rollback()
+
+	before, err := checkInState(ctx, tx, kind, id)
+	if err != nil {
+		return err
+	}
+
+	var screeningId, errorCode, entity string
+	var admitted int
+	switch kind {
+	case tickets.KindTicket:
+		entity = "ticket"
+		screeningId, admitted, errorCode, err = checkInTicket(ctx, tx, id)
+	case tickets.KindReservation:
+		entity = "reservation"
+		screeningId, admitted, errorCode, err = checkInReservation(ctx, tx, id)
+	}
+	if err != nil {
+		return err
+	}
+	if errorCode != "" {
+		return types.ErrorWithCode(errorCode)
+	}
+	after := map[string]interface{}{"checkedIn": true}
+	if kind == tickets.KindReservation {
+		previouslyAdmitted, _ := before["admitted"].(int)
+		after = map[string]interface{}{"status": before["status"], "admitted": previouslyAdmitted + admitted}
+	}
+	err = audit.Record(ctx, tx, audit.ActionUpdate, entity, id, before, after)
+	if err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	status, err := checkInStatus(ctx, screeningId)
+	if err != nil {
+		return err
+	}
+	status.Admitted = admitted
+	w.Header().Set("Content-Type", "text/json")
+	err = json.NewEncoder(w).Encode(status)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to send check-in status")
+	}
+	return nil
+}
+
+// checkInState reads the check-in state of the ticket or reservation before
+// the check-in for the audit log. For tickets, the state contains if the
+// ticket has been used already. For reservations, it contains the status of
+// the reservation and the number of admitted guests. If the ticket or the
+// reservation does not exist, nil is returned
+func checkInState(ctx context.Context, tx *sql.Tx, kind tickets.Kind, id string) (map[string]interface{}, error) {
+	if kind == tickets.KindTicket {
+		row, err := globals.SqlQueries.QueryRowContext(ctx, tx, "get-ticket-check-in", id)
+		if err != nil {
+			return nil, err
+		}
+		var screeningId string
+		var checkedIn bool
+		err = row.Scan(&screeningId, &checkedIn)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"checkedIn": checkedIn}, nil
+	}
+	row, err := globals.SqlQueries.QueryRowContext(ctx, tx, "get-reservation-admissions", id)
+	if err != nil {
+		return nil, err
+	}
+	var status string
+	var admitted int
+	err = row.Scan(&status, &admitted)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"status": status, "admitted": admitted}, nil
+}
+
+// checkInTicket marks a single ticket as used. If the ticket cannot be
+// checked in, the code of the error which should be sent to the client is
+// returned
+func checkInTicket(ctx context.Context, tx *sql.Tx, ticketId string) (screeningId string, admitted int, errorCode string, err error) {
+	row, err := globals.SqlQueries.QueryRowContext(ctx, tx, "check-in-ticket", ticketId)
+	if err != nil {
+		return "", 0, "", err
+	}
+	err = row.Scan(&screeningId)
+	if err == nil {
+		return screeningId, 1, "", nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return "", 0, "", err
+	}
+
+	// since no ticket was updated, find out if the ticket does not exist or
+	// has already been used
+	row, err = globals.SqlQueries.QueryRowContext(ctx, tx, "get-ticket-check-in", ticketId)
+	if err != nil {
+		return "", 0, "", err
+	}
+	var checkedIn bool
+	err = row.Scan(&screeningId, &checkedIn)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", 0, "TICKET_NOT_FOUND", nil
+	}
+	if err != nil {
+		return "", 0, "", err
+	}
+	return "", 0, "TICKET_ALREADY_USED", nil
+}
+
+// checkInReservation marks every ticket of a claimed reservation as used. If
+// the reservation cannot be checked in, the code of the error which should be
+// sent to the client is returned
+func checkInReservation(ctx context.Context, tx *sql.Tx, reservationId string) (screeningId string, admitted int, errorCode string, err error) {
+	rows, err := globals.SqlQueries.QueryContext(ctx, tx, "check-in-reservation", reservationId)
+	if err != nil {
+		return "", 0, "", err
+	}
+	var screenings []string
+	if err = scan.Rows(&screenings, rows); err != nil {
+		return "", 0, "", err
+	}
+	if len(screenings) > 0 {
+		return screenings[0], len(screenings), "", nil
+	}
+
+	// since no ticket was updated, find out why the reservation could not be
+	// checked in
+	row, err := globals.SqlQueries.QueryRowContext(ctx, tx, "get-reservation-check-in", reservationId)
+	if err != nil {
+		return "", 0, "", err
+	}
+	var status string
+	err = row.Scan(&screeningId, &status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", 0, "RESERVATION_NOT_FOUND", nil
+	}
+	if err != nil {
+		return "", 0, "", err
+	}
+	if status == "claimed" {
+		return "", 0, "TICKET_ALREADY_USED", nil
+	}
+	return "", 0, "RESERVATION_NOT_CLAIMED", nil
+}
+
+func getCheckInStatus(w http.ResponseWriter, r *http.Request) error {
+	screeningId := chi.URLParam(r, "screeningId")
+	if _, err := uuid.Parse(screeningId); err != nil {
+		return types.ErrorWithCode("INVALID_SCREENING_UUID")
+	}
+	status, err := checkInStatus(r.Context(), screeningId)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "text/json")
+	err = json.NewEncoder(w).Encode(status)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// checkInStatus returns the current number of checked in and sold tickets
+// for the screening
+func checkInStatus(ctx context.Context, screeningId string) (types.CheckInStatus, error) {
+	status := types.CheckInStatus{Screening: screeningId}
+	row, err := globals.SqlQueries.QueryRowContext(ctx, globals.Database, "count-check-ins", screeningId)
+	if err != nil {
+		return status, err
+	}
+	err = row.Scan(&status.CheckedIn, &status.SoldTickets)
+	return status, err
+}
@@ -0,0 +1,516 @@
+package routes
+
+import (
+	"context"
+	"database/sql"
+	"digitales-filmmanagement-backend/audit"
+	"digitales-filmmanagement-backend/devices"
+	"digitales-filmmanagement-backend/globals"
+	"digitales-filmmanagement-backend/middleware"
+	"digitales-filmmanagement-backend/types"
+	"encoding/json"
+	"errors"
+	"github.com/blockloop/scan/v2"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"strings"
+)
+
+// minimalPinLength contains the minimal number of digits of a staff PIN. The
+// description of the "FIELD_INVALID_PIN" error needs to match the length
+const minimalPinLength = 4
+
+// foreignKeyViolation is the PostgreSQL error code returned if a referenced
+// row does not exist
+const foreignKeyViolation = "23503"
+
+// DevicesRouter contains the routes used by the admins to register and
+// revoke the devices of the registers
+func DevicesRouter() http.Handler {
+	r := chi.NewRouter()
+	r.Use(middleware.RequireRole(types.RoleAdmin))
+	r.Get("/", middleware.Handle(getDevices))
+	r.Post("/", middleware.Handle(newDevice))
+	r.Delete("/{deviceId}", middleware.Handle(revokeDevice))
+	return r
+}
+
+// DeviceRouter contains the routes used by a device to let the staff switch
+// the active staff member and lock it. The routes are only available to
+// requests authenticated with a device key. The device can only be unlocked
+// if a key for the PIN hashes is configured
+func DeviceRouter() http.Handler {
+	r := chi.NewRouter()
+	r.Use(requireDevice)
+	r.Get("/staff", middleware.Handle(getStaffWithPin))
+	if globals.Configuration.Devices.IsEnabled() {
+		r.Post("/unlock", middleware.Handle(unlockDevice))
+	}
+	r.Post("/lock", middleware.Handle(lockDevice))
+	return r
+}
+
+// StaffRouter contains the routes used by the staff to manage the PIN used
+// to identify themselves on the devices. The PIN can only be set if a key for
+// the PIN hashes is configured
+func StaffRouter() http.Handler {
+	r := chi.NewRouter()
+	r.Use(middleware.RequireRole(types.Roles...))
+	if globals.Configuration.Devices.IsEnabled() {
+		r.Put("/pin", middleware.Handle(setStaffPin))
+	}
+	return r
+}
+
+// requireDevice only lets requests pass which have been authenticated using
+// a device key
+func requireDevice(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if middleware.Device(r.Context()) == nil {
+			middleware.Fail(w, r, types.ErrorWithCode("DEVICE_REQUIRED"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func getDevices(w http.ResponseWriter, r *http.Request) error {
+	rows, err := globals.SqlQueries.QueryContext(r.Context(), globals.Database, "get-devices")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	// the roles are stored as text array, therefore the rows are scanned
+	// manually
+	var deviceList []types.Device
+	for rows.Next() {
+		var device types.Device
+		var roles []string
+		err = rows.Scan(&device.ID, &device.Name, &device.Register, pq.Array(&roles), &device.CreatedBy,
+			&device.CreatedAt, &device.RevokedAt, &device.UnlockedBy, &device.UnlockedAt)
+		if err != nil {
+			return err
+		}
+		for _, role := range roles {
+			device.Roles = append(device.Roles, types.Role(role))
+		}
+		deviceList = append(deviceList, device)
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	if len(deviceList) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "text/json")
+	err = json.NewEncoder(w).Encode(deviceList)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// newDevice registers a device for a register and returns its key. The key
+// is only returned once, since the database only contains its hash
+func newDevice(w http.ResponseWriter, r *http.Request) error {
+	// access the request context
+	ctx := r.Context()
+
+	createdBy, _ := ctx.Value("user").(string)
+
+	var device types.Device
+	if err := json.NewDecoder(r.Body).Decode(&device); err != nil {
+		log.Warn().Err(err).Str("error", "INVALID_DEVICE").Msg("received invalid json payload")
+		return types.ErrorWithCode("INVALID_DEVICE")
+	}
+	device.Name = strings.TrimSpace(device.Name)
+	var invalidFields []types.FieldError
+	if device.Name == "" {
+		invalidFields = append(invalidFields, types.FieldError{Field: "name", Code: "FIELD_EMPTY"})
+	}
+	if _, err := uuid.Parse(device.Register); err != nil {
+		invalidFields = append(invalidFields, types.FieldError{Field: "register", Code: "FIELD_INVALID_UUID"})
+	}
+	if len(device.Roles) == 0 {
+		invalidFields = append(invalidFields, types.FieldError{Field: "roles", Code: "FIELD_EMPTY"})
+	}
+	// devices are shared by the staff, therefore they may not be granted the
+	// admin role
+	roles := make([]string, 0, len(device.Roles))
+	for _, role := range device.Roles {
+		if !role.IsKnown() || role == types.RoleAdmin {
+			invalidFields = append(invalidFields, types.FieldError{Field: "roles", Code: "FIELD_ROLE_NOT_ALLOWED"})
+			continue
+		}
+		roles = append(roles, string(role))
+	}
+	if len(invalidFields) > 0 {
+		return types.ValidationError("INVALID_DEVICE", invalidFields...)
+	}
+
+	key, err := devices.GenerateKey()
+	if err != nil {
+		return err
+	}
+	tx, err := globals.Database.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	// the rollback is a no-op if the transaction has been committed already
+	defer tx.Rollback()
+
+	row, err := globals.SqlQueries.QueryRowContext(ctx, tx, "insert-device",
+		device.Name, device.Register, pq.Array(roles), devices.HashKey(key), createdBy)
+	if err != nil {
+		return err
+	}
+	var deviceId string
+	err = row.Scan(&deviceId, &device.CreatedAt)
+	var databaseError *pq.Error
+	if errors.As(err, &databaseError) && databaseError.Code == foreignKeyViolation {
+		return types.ErrorWithCode("REGISTER_NOT_FOUND")
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("error while inserting device")
+		return err
+	}
+	device.ID = &deviceId
+	device.CreatedBy = createdBy
+	device.RevokedAt = nil
+	device.UnlockedBy = nil
+	device.UnlockedAt = nil
+	err = audit.Record(ctx, tx, audit.ActionCreate, "device", deviceId, nil, device)
+	if err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	log.Info().Str("device", deviceId).Str("register", device.Register).Str("by", createdBy).
+		Msg("registered new device")
+
+	w.Header().Set("Content-Type", "text/json")
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(types.DeviceCredentials{Device: device, Key: key})
+	if err != nil {
+		log.Error().Err(err).Msg("unable to send device credentials")
+	}
+	return nil
+}
+
+// revokeDevice revokes the key of a device. The device cannot be used anymore
+// and needs to be registered again
+func revokeDevice(w http.ResponseWriter, r *http.Request) error {
+	// access the request context
+	ctx := r.Context()
+
+	deviceId := chi.URLParam(r, "deviceId")
+	if _, err := uuid.Parse(deviceId); err != nil {
+		return types.ErrorWithCode("INVALID_DEVICE_UUID")
+	}
+	tx, err := globals.Database.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	// the rollback is a no-op if the transaction has been committed already
+	defer tx.Rollback()
+
+	result, err := globals.SqlQueries.ExecContext(ctx, tx, "revoke-device", deviceId)
+	if err != nil {
+		return err
+	}
+	revoked, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if revoked == 0 {
+		return types.ErrorWithCode("DEVICE_NOT_FOUND")
+	}
+	err = audit.Record(ctx, tx, audit.ActionDelete, "device", deviceId, map[string]interface{}{"id": deviceId}, nil)
+	if err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	log.Info().Str("device", deviceId).Msg("revoked device")
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// getStaffWithPin lists the staff members who have set a PIN. The device
+// lets the staff member pick their entry before entering the PIN
+func getStaffWithPin(w http.ResponseWriter, r *http.Request) error {
+	rows, err := globals.SqlQueries.QueryContext(r.Context(), globals.Database, "get-staff-with-pin")
+	if err != nil {
+		return err
+	}
+	var staff []types.ActiveStaff
+	if err = scan.Rows(&staff, rows); err != nil {
+		return err
+	}
+	if len(staff) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "text/json")
+	err = json.NewEncoder(w).Encode(staff)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// unlockDevice switches the active staff member of the device to the selected
+// member if the PIN matches the PIN of this member. Every transaction booked
+// on the device is attributed to the staff member until the device is locked
+// or another member enters their PIN. Invalid PINs are counted for the device
+// and for the selected member. After too many invalid PINs, the device or the
+// member is locked out for the configured duration. While unlocked, the
+// device only grants the roles which the staff member holds as well
+func unlockDevice(w http.ResponseWriter, r *http.Request) error {
+	// access the request context
+	ctx := r.Context()
+
+	deviceId := *middleware.Device(ctx)
+	c := globals.Configuration.Devices
+
+	var unlock types.DeviceUnlock
+	if err := json.NewDecoder(r.Body).Decode(&unlock); err != nil {
+		log.Warn().Err(err).Str("error", "INVALID_JSON").Msg("received invalid json payload")
+		return types.ErrorWithCode("INVALID_JSON")
+	}
+
+	tx, err := globals.Database.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	// the rollback is a no-op if the transaction has been committed already
+	defer tx.Rollback()
+
+	// the device is locked to count concurrent attempts correctly
+	row, err := globals.SqlQueries.QueryRowContext(ctx, tx, "lock-device-pin-attempts", deviceId)
+	if err != nil {
+		return err
+	}
+	var blocked bool
+	if err = row.Scan(&blocked); err != nil {
+		return err
+	}
+	if blocked {
+		return types.ErrorWithCode("PIN_ATTEMPTS_EXCEEDED")
+	}
+
+	// the staff member is locked as well, since the failed attempts are
+	// counted per member, too
+	row, err = globals.SqlQueries.QueryRowContext(ctx, tx, "lock-staff-pin", unlock.User)
+	if err != nil {
+		return err
+	}
+	staff := types.ActiveStaff{User: unlock.User}
+	var pinHash string
+	var staffBlocked bool
+	var staffRoleNames []string
+	err = row.Scan(&staff.Name, &pinHash, &staffBlocked, pq.Array(&staffRoleNames))
+	knownStaff := err == nil
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	if staffBlocked {
+		return types.ErrorWithCode("PIN_ATTEMPTS_EXCEEDED")
+	}
+	if !knownStaff || !devices.PinMatches(pinHash, unlock.Pin) {
+		log.Warn().Str("device", deviceId).Str("staff", unlock.User).Msg("device unlock with invalid pin")
+		_, err = globals.SqlQueries.ExecContext(ctx, tx, "record-failed-pin-attempt",
+			deviceId, *c.MaxPinAttempts, c.Lockout().Seconds())
+		if err == nil && knownStaff {
+			_, err = globals.SqlQueries.ExecContext(ctx, tx, "record-failed-staff-pin-attempt",
+				unlock.User, *c.MaxPinAttempts, c.Lockout().Seconds())
+		}
+		if err == nil {
+			err = tx.Commit()
+		}
+		if err != nil {
+			return err
+		}
+		return types.ErrorWithCode("INVALID_PIN")
+	}
+
+	// the staff member may only use the device if they hold at least one of
+	// the roles of the device
+	staffRoles := make([]types.Role, 0, len(staffRoleNames))
+	for _, name := range staffRoleNames {
+		staffRoles = append(staffRoles, types.Role(name))
+	}
+	staff.Roles = middleware.SharedRoles(middleware.DeviceRoles(ctx), staffRoles)
+	if len(staff.Roles) == 0 {
+		return types.ErrorWithCode("STAFF_ROLE_MISSING")
+	}
+
+	if _, err = globals.SqlQueries.ExecContext(ctx, tx, "reset-staff-pin-attempts", staff.User); err != nil {
+		return err
+	}
+	previousStaff, err := activeStaff(ctx, tx, deviceId)
+	if err != nil {
+		return err
+	}
+	if _, err = globals.SqlQueries.ExecContext(ctx, tx, "unlock-device", deviceId, staff.User, staff.Name); err != nil {
+		return err
+	}
+	err = audit.Record(ctx, tx, audit.ActionUpdate, "device", deviceId,
+		map[string]interface{}{"unlockedBy": previousStaff}, map[string]interface{}{"unlockedBy": staff})
+	if err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "text/json")
+	err = json.NewEncoder(w).Encode(staff)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to send active staff member")
+	}
+	return nil
+}
+
+// lockDevice locks the device. Until it is unlocked again, the device cannot
+// access any route except the unlock route
+func lockDevice(w http.ResponseWriter, r *http.Request) error {
+	// access the request context
+	ctx := r.Context()
+
+	deviceId := *middleware.Device(ctx)
+	tx, err := globals.Database.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	// the rollback is a no-op if the transaction has been committed already
+	defer tx.Rollback()
+
+	previousStaff, err := activeStaff(ctx, tx, deviceId)
+	if err != nil {
+		return err
+	}
+	if _, err = globals.SqlQueries.ExecContext(ctx, tx, "lock-device", deviceId); err != nil {
+		return err
+	}
+	err = audit.Record(ctx, tx, audit.ActionUpdate, "device", deviceId,
+		map[string]interface{}{"unlockedBy": previousStaff}, map[string]interface{}{"unlockedBy": nil})
+	if err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// activeStaff locks the device for the remainder of the supplied database
+// transaction and returns the staff member who unlocked it. If the device is
+// locked, nil is returned
+func activeStaff(ctx context.Context, tx *sql.Tx, deviceId string) (*types.ActiveStaff, error) {
+	row, err := globals.SqlQueries.QueryRowContext(ctx, tx, "lock-device-active-staff", deviceId)
+	if err != nil {
+		return nil, err
+	}
+	var user, name sql.NullString
+	if err = row.Scan(&user, &name); err != nil {
+		return nil, err
+	}
+	if !user.Valid {
+		return nil, nil
+	}
+	return &types.ActiveStaff{User: user.String, Name: name.String}, nil
+}
+
+// setStaffPin sets the PIN the authenticated user enters to unlock a device.
+// Only users authenticated using OpenID Connect may set their PIN
+func setStaffPin(w http.ResponseWriter, r *http.Request) error {
+	// access the request context
+	ctx := r.Context()
+
+	userId, validUser := ctx.Value("userId").(string)
+	userName, _ := ctx.Value("user").(string)
+	if !validUser || userId == "" || middleware.Device(ctx) != nil {
+		return types.ErrorWithCode("FORBIDDEN")
+	}
+
+	var staffPin types.StaffPin
+	if err := json.NewDecoder(r.Body).Decode(&staffPin); err != nil {
+		log.Warn().Err(err).Str("error", "INVALID_JSON").Msg("received invalid json payload")
+		return types.ErrorWithCode("INVALID_JSON")
+	}
+	if !validPin(staffPin.Pin) {
+		return types.ValidationError("INVALID_PIN_FORMAT",
+			types.FieldError{Field: "pin", Code: "FIELD_INVALID_PIN"})
+	}
+
+	tx, err := globals.Database.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	// the rollback is a no-op if the transaction has been committed already
+	defer tx.Rollback()
+
+	// the previous name is read for the audit log. the PIN itself is never
+	// written into the audit log
+	row, err := globals.SqlQueries.QueryRowContext(ctx, tx, "lock-staff-pin", userId)
+	if err != nil {
+		return err
+	}
+	var previousName, previousHash string
+	var blocked bool
+	var previousRoles []string
+	action := audit.ActionUpdate
+	var before interface{}
+	err = row.Scan(&previousName, &previousHash, &blocked, pq.Array(&previousRoles))
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		action = audit.ActionCreate
+	case err != nil:
+		return err
+	default:
+		before = map[string]interface{}{"name": previousName}
+	}
+
+	// the roles of the user are stored with the PIN, since they limit the
+	// roles of the devices unlocked by the user
+	roles, _ := ctx.Value("roles").([]types.Role)
+	roleNames := make([]string, 0, len(roles))
+	for _, role := range roles {
+		roleNames = append(roleNames, string(role))
+	}
+	_, err = globals.SqlQueries.ExecContext(ctx, tx, "upsert-staff-pin",
+		userId, userName, devices.HashPin(staffPin.Pin), pq.Array(roleNames))
+	if err != nil {
+		return err
+	}
+	err = audit.Record(ctx, tx, action, "staff-pin", userId, before, map[string]interface{}{"name": userName})
+	if err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// validPin reports if the PIN only consists of digits and is long enough
+func validPin(pin string) bool {
+	if len(pin) < minimalPinLength {
+		return false
+	}
+	for _, digit := range pin {
+		if digit < '0' || digit > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,131 @@
+package routes
+
+import (
+	"context"
+	"digitales-filmmanagement-backend/globals"
+	"digitales-filmmanagement-backend/types"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Liveness reports that the server is able to handle requests. It does not
+// check any dependencies, since a restart of the backend would not fix an
+// unavailable database
+func Liveness(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Content-Type", "text/json")
+	return json.NewEncoder(w).Encode(types.HealthStatus{Status: types.HealthStatusUp})
+}
+
+// readiness contains the result of the last readiness checks. The mutex is
+// held while the dependencies are checked, therefore concurrent requests wait
+// for the running checks instead of starting their own
+var readiness struct {
+	sync.Mutex
+	status    types.HealthStatus
+	checkedAt time.Time
+}
+
+// Readiness reports the connections to both databases and the reachability
+// of every configured authorization server. Since the endpoint is available
+// without authentication, the result of the checks is reused for the
+// configured duration. If any of the dependencies is unavailable, the status
+// code 503 is used
+func Readiness(w http.ResponseWriter, r *http.Request) error {
+	readiness.Lock()
+	if time.Since(readiness.checkedAt) >= globals.Configuration.Server.HealthCheckCache() {
+		readiness.status = checkDependencies()
+		readiness.checkedAt = time.Now()
+	}
+	status := readiness.status
+	readiness.Unlock()
+
+	w.Header().Set("Content-Type", "text/json")
+	if status.Status != types.HealthStatusUp {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	return json.NewEncoder(w).Encode(status)
+}
+
+// checkDependencies checks the dependencies in parallel. Every check fails
+// after the configured timeout. The checks do not use the context of the
+// request, since their result is shared with other requests
+func checkDependencies() types.HealthStatus {
+	timeout := globals.Configuration.Server.HealthCheck()
+	checks := map[string]func(ctx context.Context) error{
+		"postgres":  globals.Database.PingContext,
+		"wordpress": globals.WpDatabase.PingContext,
+	}
+	for _, provider := range globals.Configuration.IdentityProviders() {
+		endpoint := *provider.UserInfoEndpoint
+		if provider.DiscoveryEndpoint != nil {
+			endpoint = *provider.DiscoveryEndpoint
+		}
+		checks["idp:"+provider.DisplayName()] = func(ctx context.Context) error {
+			return checkEndpoint(ctx, endpoint)
+		}
+	}
+
+	status := types.HealthStatus{Status: types.HealthStatusUp, Checks: make(map[string]types.DependencyStatus)}
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check func(ctx context.Context) error) {
+			defer wg.Done()
+			dependency := checkDependency(context.Background(), name, timeout, check)
+			mutex.Lock()
+			defer mutex.Unlock()
+			status.Checks[name] = dependency
+			if dependency.Status != types.HealthStatusUp {
+				status.Status = types.HealthStatusDown
+			}
+		}(name, check)
+	}
+	wg.Wait()
+	return status
+}
+
+// checkDependency executes the check of a single dependency. The cause of a
+// failed check is only logged, since the endpoint is available without
+// authentication
+func checkDependency(ctx context.Context, name string, timeout time.Duration, check func(ctx context.Context) error) types.DependencyStatus {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	start := time.Now()
+	err := check(ctx)
+	dependency := types.DependencyStatus{Status: types.HealthStatusUp, Latency: time.Since(start).Milliseconds()}
+	if err == nil {
+		return dependency
+	}
+	log.Warn().Err(err).Str("dependency", name).Msg("dependency unavailable")
+	dependency.Status = types.HealthStatusDown
+	dependency.Detail = "unreachable"
+	if errors.Is(err, context.DeadlineExceeded) {
+		dependency.Detail = "timeout"
+	}
+	return dependency
+}
+
+// checkEndpoint requests the endpoint of an authorization server. The server
+// is reachable if it does not answer with a server error. Therefore, the
+// userinfo endpoint may be checked without a token as well
+func checkEndpoint(ctx context.Context, endpoint string) error {
+	request, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	response, err := globals.HttpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= 500 {
+		return fmt.Errorf("unexpected response code %d", response.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+package routes
+
+import (
+	"digitales-filmmanagement-backend/middleware"
+	"digitales-filmmanagement-backend/types"
+	"encoding/json"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+)
+
+func MonitoringRouter() http.Handler {
+	r := chi.NewRouter()
+	r.Use(middleware.RequireRole(types.RoleAdmin))
+	r.Get("/userinfo-cache", middleware.Handle(userInfoCacheStatistics))
+	return r
+}
+
+func userInfoCacheStatistics(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Content-Type", "text/json")
+	return json.NewEncoder(w).Encode(middleware.UserInfoCacheStatistics())
+}
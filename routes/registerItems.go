@@ -8,38 +8,23 @@ import (
 	"net/http"
 )
 
-func GetAllRegisterItems(w http.ResponseWriter, r *http.Request) {
-	// access the request context and get the global error handler
-	ctx := r.Context()
-	errorHandler := ctx.Value("nativeErrorChannel").(chan error)
-	handledError := ctx.Value("nativeErrorHandled").(chan bool)
+func GetAllRegisterItems(w http.ResponseWriter, r *http.Request) error {
 	// now try to get all register items from the database
-	rows, err := globals.SqlQueries.Query(globals.Database, "get-register-items")
+	rows, err := globals.SqlQueries.QueryContext(r.Context(), globals.Database, "get-register-items")
 	if err != nil {
-		// send error to the error handler
-		errorHandler <- err
-		// wait until the error was handled
-		<-handledError
-		return
+		return err
 	}
 	// now create an array of register items
 	var items []types.RegisterItem
 	// now scan the result rows into the array
 	if err = scan.Rows(&items, rows); err != nil {
-		// send error to the error handler
-		errorHandler <- err
-		// wait until the error was handled
-		<-handledError
-		return
+		return err
 	}
 	// now return the available register items
 	w.Header().Set("Content-Type", "text/json")
 	err = json.NewEncoder(w).Encode(items)
 	if err != nil {
-		// send error to the error handler
-		errorHandler <- err
-		// wait until the error was handled
-		<-handledError
-		return
+		return err
 	}
+	return nil
 }
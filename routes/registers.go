@@ -1,7 +1,10 @@
 package routes
 
 import (
+	"digitales-filmmanagement-backend/audit"
 	"digitales-filmmanagement-backend/globals"
+	"digitales-filmmanagement-backend/metrics"
+	"digitales-filmmanagement-backend/middleware"
 	"digitales-filmmanagement-backend/types"
 	"encoding/json"
 	"github.com/blockloop/scan/v2"
@@ -11,64 +14,48 @@ import (
 	"net/http"
 )
 
-func GetAllRegisters(w http.ResponseWriter, r *http.Request) {
-	// access the request context and get the global error handler
-	ctx := r.Context()
-	errorHandler := ctx.Value("nativeErrorChannel").(chan error)
-	handledError := ctx.Value("nativeErrorHandled").(chan bool)
+func GetAllRegisters(w http.ResponseWriter, r *http.Request) error {
 	// now try to get all register items from the database
-	rows, err := globals.SqlQueries.Query(globals.Database, "get-registers")
+	rows, err := globals.SqlQueries.QueryContext(r.Context(), globals.Database, "get-registers")
 	if err != nil {
-		// send error to the error handler
-		errorHandler <- err
-		// wait until the error was handled
-		<-handledError
-		return
+		return err
 	}
 	// now create an array of register items
 	var items []types.Register
 	// now scan the result rows into the array
 	if err = scan.Rows(&items, rows); err != nil {
-		// send error to the error handler
-		errorHandler <- err
-		// wait until the error was handled
-		<-handledError
-		return
+		return err
 	}
 	if len(items) == 0 {
 		w.WriteHeader(http.StatusNoContent)
-		return
+		return nil
 	}
 	// now return the available register items
 	w.Header().Set("Content-Type", "text/json")
 	err = json.NewEncoder(w).Encode(items)
 	if err != nil {
-		// send error to the error handler
-		errorHandler <- err
-		// wait until the error was handled
-		<-handledError
-		return
+		return err
 	}
+	return nil
 }
 
-func NewRegisterTransaction(w http.ResponseWriter, r *http.Request) {
-	// access the request context and get the global error handler
+func NewRegisterTransaction(w http.ResponseWriter, r *http.Request) error {
+	// access the request context
 	ctx := r.Context()
-	nativeErrorHandler := ctx.Value("nativeErrorChannel").(chan error)
-	handledNativeError := ctx.Value("nativeErrorHandled").(chan bool)
-	apiErrorHandler := ctx.Value("apiErrorChannel").(chan string)
-	handledApiError := ctx.Value("apiErrorHandled").(chan bool)
 
-	// now get the full name of the person responsible for the transaction
-	responsiblePerson := ctx.Value("user").(string)
+	// now get the identity of the person responsible for the transaction
+	responsibleId := middleware.UserID(ctx)
+	responsiblePerson := middleware.UserName(ctx)
 
 	// now first get the register id from the request
 	registerId := chi.URLParam(r, "registerId")
 	// now check if the uuid is valid
 	if _, err := uuid.Parse(registerId); err != nil {
-		apiErrorHandler <- "INVALID_REGISTER_UUID"
-		<-handledApiError
-		return
+		return types.ErrorWithCode("INVALID_REGISTER_UUID")
+	}
+	// devices may only book transactions in the register they are bound to
+	if !middleware.AllowsRegister(ctx, registerId) {
+		return types.ErrorWithCode("DEVICE_REGISTER_MISMATCH")
 	}
 
 	// now try and parse the request body
@@ -77,24 +64,16 @@ func NewRegisterTransaction(w http.ResponseWriter, r *http.Request) {
 		switch err := err.(type) {
 		case *json.SyntaxError:
 			log.Warn().Err(err).Str("error", "INVALID_JSON").Msg("received invalid json payload")
-			apiErrorHandler <- "INVALID_JSON"
-			<-handledApiError
-			return
+			return types.ErrorWithCode("INVALID_JSON")
 		case *json.UnmarshalTypeError:
 			log.Warn().Err(err).Str("error", "INVALID_TRANSACTION").Msg("received invalid json payload")
-			apiErrorHandler <- "INVALID_TRANSACTION"
-			<-handledApiError
-			return
+			return types.ErrorWithCode("INVALID_TRANSACTION")
 		case *json.InvalidUnmarshalError:
 			log.Error().Err(err).Msg("invalid unmarshal argument")
-			nativeErrorHandler <- err
-			<-handledNativeError
-			return
+			return err
 		default:
 			log.Error().Err(err).Msg("unable to unmarshal into struct")
-			nativeErrorHandler <- err
-			<-handledNativeError
-			return
+			return err
 		}
 	}
 
@@ -104,29 +83,63 @@ func NewRegisterTransaction(w http.ResponseWriter, r *http.Request) {
 		Description: &registerTransaction.Description,
 		Amount:      registerTransaction.Total,
 		By:          responsiblePerson,
+		BySubject:   optionalSubject(responsibleId),
 		Register:    registerId,
+		Device:      middleware.Device(ctx),
 	}
-	_, err := globals.SqlQueries.Exec(globals.Database, "insert-transaction",
-		transaction.Title, transaction.Description, transaction.Amount, transaction.By, transaction.Register)
+	// the transaction, the article statistics and the audit entry are stored
+	// together
+	tx, err := globals.Database.BeginTx(ctx, nil)
 	if err != nil {
+		return err
+	}
+	// the rollback is a no-op if the transaction has been committed already
+	defer tx.Rollback()
+
+	row, err := globals.SqlQueries.QueryRowContext(ctx, tx, "insert-transaction",
+		transaction.Title, transaction.Description, transaction.Amount, transaction.By, transaction.Register,
+		transaction.Device, transaction.BySubject)
+	if err != nil {
+		log.Error().Err(err).Msg("error while inserting transaction")
+		return err
+	}
+	var transactionId string
+	if err = row.Scan(&transactionId); err != nil {
 		log.Error().Err(err).Msg("error while inserting transaction")
-		nativeErrorHandler <- err
-		<-handledNativeError
-		return
+		return err
 	}
+	transaction.ID = &transactionId
 	// now insert the statistics
 	for articleName, articleCount := range registerTransaction.Articles {
-		_, err = globals.SqlQueries.Exec(globals.Database, "insert-article-sale",
+		_, err = globals.SqlQueries.ExecContext(ctx, tx, "insert-article-sale",
 			articleName, articleCount)
 		if err != nil {
 			log.Error().Err(err).Msg("error while inserting article statistics")
-			nativeErrorHandler <- err
-			<-handledNativeError
-			return
+			return err
 		}
 	}
+	err = audit.Record(ctx, tx, audit.ActionCreate, "transaction", transactionId, nil, map[string]interface{}{
+		"transaction": transaction, "articleCounts": registerTransaction.Articles,
+	})
+	if err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	metrics.RecordTransaction(registerId, transaction.Amount)
 
 	// now report back that the transaction was completely stored in the database
 	w.WriteHeader(http.StatusCreated)
-	return
+	return nil
+}
+
+// optionalSubject returns nil if the subject is empty, e.g. for requests
+// authenticated without an OpenID Connect identity. Therefore, NULL is stored
+// instead of an empty subject
+func optionalSubject(subject string) *string {
+	if subject == "" {
+		return nil
+	}
+	return &subject
 }
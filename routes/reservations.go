@@ -0,0 +1,294 @@
+package routes
+
+import (
+	"context"
+	"database/sql"
+	"digitales-filmmanagement-backend/audit"
+	"digitales-filmmanagement-backend/globals"
+	"digitales-filmmanagement-backend/metrics"
+	"digitales-filmmanagement-backend/middleware"
+	"digitales-filmmanagement-backend/types"
+	"encoding/json"
+	"errors"
+	"github.com/blockloop/scan/v2"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+func getReservations(w http.ResponseWriter, r *http.Request) error {
+	screeningId := chi.URLParam(r, "screeningId")
+	if _, err := uuid.Parse(screeningId); err != nil {
+		return types.ErrorWithCode("INVALID_SCREENING_UUID")
+	}
+
+	rows, err := globals.SqlQueries.QueryContext(r.Context(), globals.Database, "get-reservations", screeningId)
+	if err != nil {
+		return err
+	}
+	var reservations []types.Reservation
+	if err = scan.Rows(&reservations, rows); err != nil {
+		return err
+	}
+	if len(reservations) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "text/json")
+	err = json.NewEncoder(w).Encode(reservations)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// newReservation reserves seats for a guest. The seats count towards the
+// capacity of the screening until the reservation is claimed, cancelled or
+// released by the background job shortly before the screening starts
+func newReservation(w http.ResponseWriter, r *http.Request) error {
+	// access the request context
+	ctx := r.Context()
+
+	screeningId := chi.URLParam(r, "screeningId")
+	if _, err := uuid.Parse(screeningId); err != nil {
+		return types.ErrorWithCode("INVALID_SCREENING_UUID")
+	}
+
+	// now try and parse the request body
+	var reservation types.Reservation
+	if err := json.NewDecoder(r.Body).Decode(&reservation); err != nil {
+		log.Warn().Err(err).Str("error", "INVALID_RESERVATION").Msg("received invalid json payload")
+		return types.ErrorWithCode("INVALID_RESERVATION")
+	}
+	var invalidFields []types.FieldError
+	if strings.TrimSpace(reservation.Name) == "" {
+		invalidFields = append(invalidFields, types.FieldError{Field: "name", Code: "FIELD_EMPTY"})
+	}
+	if _, err := mail.ParseAddress(reservation.Email); err != nil {
+		invalidFields = append(invalidFields, types.FieldError{Field: "email", Code: "FIELD_INVALID_EMAIL"})
+	}
+	if reservation.Seats < 1 {
+		invalidFields = append(invalidFields, types.FieldError{Field: "seats", Code: "FIELD_TOO_SMALL"})
+	}
+	if len(invalidFields) > 0 {
+		return types.ValidationError("INVALID_RESERVATION", invalidFields...)
+	}
+
+	tx, err := globals.Database.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	// the rollback is a no-op if the transaction has been committed already
+	defer tx.Rollback()
+
+	screening, err := lockScreening(ctx, tx, screeningId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return types.ErrorWithCode("SCREENING_NOT_FOUND")
+	}
+	if err != nil {
+		return err
+	}
+	// reservations are only accepted as long as they would be held
+	holdDuration := globals.Configuration.Reservations.HoldDuration()
+	if time.Now().After(screening.StartsAt.Add(-holdDuration)) {
+		return types.ErrorWithCode("RESERVATIONS_CLOSED")
+	}
+
+	row, err := globals.SqlQueries.QueryRowContext(ctx, tx, "count-occupied-seats", screeningId)
+	if err != nil {
+		return err
+	}
+	var occupiedSeats int
+	if err = row.Scan(&occupiedSeats); err != nil {
+		return err
+	}
+	if occupiedSeats+reservation.Seats > screening.EffectiveCapacity {
+		return types.ErrorWithCode("SCREENING_SOLD_OUT")
+	}
+
+	row, err = globals.SqlQueries.QueryRowContext(ctx, tx, "insert-reservation",
+		screeningId, reservation.Name, reservation.Email, reservation.Seats)
+	if err != nil {
+		return err
+	}
+	var reservationId string
+	if err = row.Scan(&reservationId, &reservation.Status, &reservation.CreatedAt); err != nil {
+		log.Error().Err(err).Msg("error while inserting reservation")
+		return err
+	}
+	reservation.ID = &reservationId
+	reservation.Screening = screeningId
+	reservation.Transaction = nil
+	err = audit.Record(ctx, tx, audit.ActionCreate, "reservation", reservationId, nil, reservation)
+	if err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "text/json")
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(reservation)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to send created reservation")
+	}
+	return nil
+}
+
+func cancelReservation(w http.ResponseWriter, r *http.Request) error {
+	// access the request context
+	ctx := r.Context()
+
+	screeningId, reservationId, valid := reservationParameters(r)
+	if !valid {
+		return types.ErrorWithCode("INVALID_RESERVATION_UUID")
+	}
+
+	tx, err := globals.Database.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	// the rollback is a no-op if the transaction has been committed already
+	defer tx.Rollback()
+
+	_, status, err := lockReservation(ctx, tx, screeningId, reservationId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return types.ErrorWithCode("RESERVATION_NOT_FOUND")
+	}
+	if err != nil {
+		return err
+	}
+	if status != "held" {
+		return types.ErrorWithCode("RESERVATION_NOT_HELD")
+	}
+
+	if _, err = globals.SqlQueries.ExecContext(ctx, tx, "cancel-reservation", reservationId); err != nil {
+		return err
+	}
+	err = audit.Record(ctx, tx, audit.ActionUpdate, "reservation", reservationId, map[string]interface{}{"status": status}, map[string]interface{}{"status": "cancelled"})
+	if err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// claimReservation converts a held reservation into a ticket sale at the door.
+// The reserved seats are already counted towards the capacity, therefore the
+// tickets are created without another capacity check
+func claimReservation(w http.ResponseWriter, r *http.Request) error {
+	// access the request context
+	ctx := r.Context()
+
+	// now get the identity of the person responsible for the sale
+	responsibleId := middleware.UserID(ctx)
+	responsiblePerson := middleware.UserName(ctx)
+
+	screeningId, reservationId, valid := reservationParameters(r)
+	if !valid {
+		return types.ErrorWithCode("INVALID_RESERVATION_UUID")
+	}
+
+	var claim types.ReservationClaim
+	if err := json.NewDecoder(r.Body).Decode(&claim); err != nil {
+		log.Warn().Err(err).Str("error", "INVALID_TICKET_BOOKING").Msg("received invalid json payload")
+		return types.ErrorWithCode("INVALID_TICKET_BOOKING")
+	}
+	if _, err := uuid.Parse(claim.Register); err != nil {
+		return types.ValidationError("INVALID_TICKET_BOOKING",
+			types.FieldError{Field: "register", Code: "FIELD_INVALID_UUID"})
+	}
+	if !middleware.AllowsRegister(ctx, claim.Register) {
+		return types.ErrorWithCode("DEVICE_REGISTER_MISMATCH")
+	}
+
+	tx, err := globals.Database.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	// the rollback is a no-op if the transaction has been committed already
+	defer tx.Rollback()
+
+	// the screening is locked first to keep the lock order of the ticket
+	// bookings and prevent deadlocks
+	screening, err := lockScreening(ctx, tx, screeningId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return types.ErrorWithCode("SCREENING_NOT_FOUND")
+	}
+	if err != nil {
+		return err
+	}
+	seats, status, err := lockReservation(ctx, tx, screeningId, reservationId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return types.ErrorWithCode("RESERVATION_NOT_FOUND")
+	}
+	if err != nil {
+		return err
+	}
+	if status != "held" {
+		return types.ErrorWithCode("RESERVATION_NOT_HELD")
+	}
+
+	transactionId, ticketIds, err := sellTickets(ctx, tx, screening, claim.Register, claim.Total,
+		responsibleId, responsiblePerson, middleware.Device(ctx), seats, false)
+	if err != nil {
+		log.Error().Err(err).Msg("error while selling reserved tickets")
+		return err
+	}
+	if _, err = globals.SqlQueries.ExecContext(ctx, tx, "claim-reservation", reservationId, transactionId); err != nil {
+		return err
+	}
+	err = audit.Record(ctx, tx, audit.ActionUpdate, "reservation", reservationId, map[string]interface{}{"status": status}, map[string]interface{}{"status": "claimed", "transaction": transactionId})
+	if err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	metrics.RecordTransaction(claim.Register, claim.Total)
+	metrics.RecordTicketSale(claim.Register, seats)
+
+	// now report back the booked tickets
+	w.Header().Set("Content-Type", "text/json")
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(ticketIds)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to send booked tickets")
+	}
+	return nil
+}
+
+// reservationParameters reads the screening and reservation UUIDs from the
+// request path and reports if both are valid UUIDs
+func reservationParameters(r *http.Request) (screeningId string, reservationId string, valid bool) {
+	screeningId = chi.URLParam(r, "screeningId")
+	reservationId = chi.URLParam(r, "reservationId")
+	if _, err := uuid.Parse(screeningId); err != nil {
+		return "", "", false
+	}
+	if _, err := uuid.Parse(reservationId); err != nil {
+		return "", "", false
+	}
+	return screeningId, reservationId, true
+}
+
+// lockReservation locks the reservation for the remainder of the supplied
+// database transaction and returns the number of reserved seats and its
+// status. If the reservation does not exist for the screening, sql.ErrNoRows
+// is returned
+func lockReservation(ctx context.Context, tx *sql.Tx, screeningId string, reservationId string) (seats int, status string, err error) {
+	row, err := globals.SqlQueries.QueryRowContext(ctx, tx, "lock-reservation", reservationId, screeningId)
+	if err != nil {
+		return 0, "", err
+	}
+	err = row.Scan(&seats, &status)
+	return seats, status, err
+}
@@ -0,0 +1,314 @@
+package routes
+
+import (
+	"context"
+	"database/sql"
+	"digitales-filmmanagement-backend/audit"
+	"digitales-filmmanagement-backend/globals"
+	"digitales-filmmanagement-backend/metrics"
+	"digitales-filmmanagement-backend/middleware"
+	"digitales-filmmanagement-backend/types"
+	"encoding/json"
+	"errors"
+	"github.com/blockloop/scan/v2"
+	"github.com/ggicci/httpin"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+func ScreeningsRouter() http.Handler {
+	r := chi.NewRouter()
+	// the programme and the shifts are available to every member of the staff
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.RequireRole(types.Roles...))
+		r.With(httpin.NewInput(types.ScreeningsRequestInput{})).
+			Get("/", middleware.Handle(getScreenings))
+		r.Get("/{screeningId}/shifts", middleware.Handle(getShifts))
+		r.Post("/{screeningId}/shifts/{shiftId}/signup", middleware.Handle(signUpForShift))
+		r.Delete("/{screeningId}/shifts/{shiftId}/signup", middleware.Handle(withdrawFromShift))
+	})
+	// the ticket sales and the check-in are handled by the door crew
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.RequireRole(types.RoleDoor))
+		r.Post("/{screeningId}/tickets", middleware.Handle(bookTickets))
+		r.Get("/{screeningId}/reservations", middleware.Handle(getReservations))
+		r.Post("/{screeningId}/reservations", middleware.Handle(newReservation))
+		r.Delete("/{screeningId}/reservations/{reservationId}", middleware.Handle(cancelReservation))
+		r.Post("/{screeningId}/reservations/{reservationId}/claim", middleware.Handle(claimReservation))
+		// the qr codes are only available if the ticket tokens can be signed
+		if globals.Configuration.Tickets.IsEnabled() {
+			r.Get("/{screeningId}/reservations/{reservationId}/qr.{format}", middleware.Handle(reservationQRCode))
+		}
+		r.Get("/{screeningId}/checkins", middleware.Handle(getCheckInStatus))
+	})
+	// the screenings and their shifts are planned by the admins
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.RequireRole(types.RoleAdmin))
+		r.Post("/", middleware.Handle(newScreening))
+		r.Put("/{screeningId}/shifts", middleware.Handle(setShifts))
+	})
+	return r
+}
+
+func getScreenings(w http.ResponseWriter, r *http.Request) error {
+	// access the request context
+	ctx := r.Context()
+	// now parse the parameters. if no start was supplied, the screenings of
+	// the current day are included as well
+	parameters := ctx.Value(httpin.Input).(*types.ScreeningsRequestInput)
+	from := time.Now().Add(-12 * time.Hour)
+	if parameters.From != nil {
+		from = time.Unix(*parameters.From, 0)
+	}
+
+	rows, err := globals.SqlQueries.QueryContext(ctx, globals.Database, "get-screenings", from)
+	if err != nil {
+		return err
+	}
+	var screenings []types.Screening
+	if err = scan.Rows(&screenings, rows); err != nil {
+		return err
+	}
+	if len(screenings) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "text/json")
+	err = json.NewEncoder(w).Encode(screenings)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func newScreening(w http.ResponseWriter, r *http.Request) error {
+	// access the request context
+	ctx := r.Context()
+
+	// now try and parse the request body
+	var screening types.Screening
+	if err := json.NewDecoder(r.Body).Decode(&screening); err != nil {
+		log.Warn().Err(err).Str("error", "INVALID_SCREENING").Msg("received invalid json payload")
+		return types.ErrorWithCode("INVALID_SCREENING")
+	}
+	// now check that the screening contains the required fields
+	var invalidFields []types.FieldError
+	if strings.TrimSpace(screening.Title) == "" {
+		invalidFields = append(invalidFields, types.FieldError{Field: "title", Code: "FIELD_EMPTY"})
+	}
+	if screening.StartsAt.IsZero() {
+		invalidFields = append(invalidFields, types.FieldError{Field: "startsAt", Code: "FIELD_REQUIRED"})
+	}
+	if _, err := uuid.Parse(screening.Venue); err != nil {
+		invalidFields = append(invalidFields, types.FieldError{Field: "venue", Code: "FIELD_INVALID_UUID"})
+	}
+	if screening.Capacity != nil && *screening.Capacity < 0 {
+		invalidFields = append(invalidFields, types.FieldError{Field: "capacity", Code: "FIELD_NEGATIVE"})
+	}
+	if len(invalidFields) > 0 {
+		return types.ValidationError("INVALID_SCREENING", invalidFields...)
+	}
+
+	tx, err := globals.Database.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	// the rollback is a no-op if the transaction has been committed already
+	defer tx.Rollback()
+
+	row, err := globals.SqlQueries.QueryRowContext(ctx, tx, "insert-screening",
+		screening.Title, screening.StartsAt, screening.Venue, screening.Capacity, screening.WordPressPostID)
+	if err != nil {
+		return err
+	}
+	var screeningId string
+	if err = row.Scan(&screeningId); err != nil {
+		log.Error().Err(err).Msg("error while inserting screening")
+		return err
+	}
+	screening.ID = &screeningId
+	err = audit.Record(ctx, tx, audit.ActionCreate, "screening", screeningId, nil, screening)
+	if err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "text/json")
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(screening)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to send created screening")
+	}
+	return nil
+}
+
+// bookTickets sells tickets for a screening. The screening is locked while the
+// tickets are booked to prevent two registers from selling the last seats at
+// the same time. If the screening is sold out, the booking is refused unless
+// a user with the admin role explicitly overrides the capacity
+func bookTickets(w http.ResponseWriter, r *http.Request) error {
+	// access the request context
+	ctx := r.Context()
+
+	// now get the identity of the person responsible for the booking
+	responsibleId := middleware.UserID(ctx)
+	responsiblePerson := middleware.UserName(ctx)
+	elevated := middleware.HasRole(ctx, types.RoleAdmin)
+
+	// now first get the screening id from the request
+	screeningId := chi.URLParam(r, "screeningId")
+	if _, err := uuid.Parse(screeningId); err != nil {
+		return types.ErrorWithCode("INVALID_SCREENING_UUID")
+	}
+
+	// now try and parse the request body
+	var booking types.TicketBooking
+	if err := json.NewDecoder(r.Body).Decode(&booking); err != nil {
+		log.Warn().Err(err).Str("error", "INVALID_TICKET_BOOKING").Msg("received invalid json payload")
+		return types.ErrorWithCode("INVALID_TICKET_BOOKING")
+	}
+	var invalidFields []types.FieldError
+	if _, err := uuid.Parse(booking.Register); err != nil {
+		invalidFields = append(invalidFields, types.FieldError{Field: "register", Code: "FIELD_INVALID_UUID"})
+	}
+	if booking.Count < 1 {
+		invalidFields = append(invalidFields, types.FieldError{Field: "count", Code: "FIELD_TOO_SMALL"})
+	}
+	if len(invalidFields) > 0 {
+		return types.ValidationError("INVALID_TICKET_BOOKING", invalidFields...)
+	}
+	if !middleware.AllowsRegister(ctx, booking.Register) {
+		return types.ErrorWithCode("DEVICE_REGISTER_MISMATCH")
+	}
+	if booking.OverrideCapacity && !elevated {
+		return types.ErrorWithCode("CAPACITY_OVERRIDE_DENIED")
+	}
+
+	tx, err := globals.Database.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	// the rollback is a no-op if the transaction has been committed already
+	defer tx.Rollback()
+
+	// now lock the screening and get its capacity
+	screening, err := lockScreening(ctx, tx, screeningId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return types.ErrorWithCode("SCREENING_NOT_FOUND")
+	}
+	if err != nil {
+		return err
+	}
+
+	// now check if enough seats are left. seats held by reservations are
+	// counted as occupied
+	row, err := globals.SqlQueries.QueryRowContext(ctx, tx, "count-occupied-seats", screeningId)
+	if err != nil {
+		return err
+	}
+	var occupiedSeats int
+	if err = row.Scan(&occupiedSeats); err != nil {
+		return err
+	}
+	overCapacity := occupiedSeats+booking.Count > screening.EffectiveCapacity
+	if overCapacity && !booking.OverrideCapacity {
+		return types.ErrorWithCode("SCREENING_SOLD_OUT")
+	}
+
+	// now book the payment and create the tickets
+	_, ticketIds, err := sellTickets(ctx, tx, screening, booking.Register, booking.Total,
+		responsibleId, responsiblePerson, middleware.Device(ctx), booking.Count, overCapacity)
+	if err != nil {
+		log.Error().Err(err).Msg("error while selling tickets")
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	metrics.RecordTransaction(booking.Register, booking.Total)
+	metrics.RecordTicketSale(booking.Register, booking.Count)
+	if overCapacity {
+		log.Warn().Str("screening", screeningId).Str("by", responsiblePerson).
+			Msg("tickets booked beyond the capacity of the screening")
+	}
+
+	// now report back the booked tickets
+	w.Header().Set("Content-Type", "text/json")
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(ticketIds)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to send booked tickets")
+	}
+	return nil
+}
+
+// lockScreening locks the screening for the remainder of the supplied database
+// transaction and returns its title, start and effective capacity. If the
+// screening does not exist, sql.ErrNoRows is returned
+func lockScreening(ctx context.Context, tx *sql.Tx, screeningId string) (types.Screening, error) {
+	screening := types.Screening{ID: &screeningId}
+	row, err := globals.SqlQueries.QueryRowContext(ctx, tx, "lock-screening-capacity", screeningId)
+	if err != nil {
+		return screening, err
+	}
+	err = row.Scan(&screening.Title, &screening.StartsAt, &screening.EffectiveCapacity)
+	return screening, err
+}
+
+// sellTickets books the payment for the tickets in the register and creates
+// the tickets for the screening in the supplied database transaction. The
+// sale is recorded in the audit log. It returns the UUID of the transaction
+// and the UUIDs of the created tickets. The seller is identified by their
+// subject, the name is stored as snapshot. The device is nil if the tickets
+// were not sold on a register device
+func sellTickets(ctx context.Context, tx *sql.Tx, screening types.Screening, register string, total float64,
+	bySubject string, by string, device *string, count int, overCapacity bool) (transactionId string, ticketIds []string, err error) {
+	description := ""
+	row, err := globals.SqlQueries.QueryRowContext(ctx, tx, "insert-transaction",
+		"Tickets: "+screening.Title, &description, total, by, register, device, optionalSubject(bySubject))
+	if err != nil {
+		return "", nil, err
+	}
+	if err = row.Scan(&transactionId); err != nil {
+		return "", nil, err
+	}
+
+	ticketIds = make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		row, err = globals.SqlQueries.QueryRowContext(ctx, tx, "insert-ticket",
+			*screening.ID, transactionId, by, overCapacity)
+		if err != nil {
+			return "", nil, err
+		}
+		var ticketId string
+		if err = row.Scan(&ticketId); err != nil {
+			return "", nil, err
+		}
+		ticketIds = append(ticketIds, ticketId)
+	}
+
+	transaction := types.Transaction{
+		ID:          &transactionId,
+		Title:       "Tickets: " + screening.Title,
+		Description: &description,
+		Amount:      total,
+		By:          by,
+		BySubject:   optionalSubject(bySubject),
+		Register:    register,
+		Device:      device,
+	}
+	err = audit.Record(ctx, tx, audit.ActionCreate, "transaction", transactionId, nil, map[string]interface{}{
+		"transaction": transaction, "screening": *screening.ID, "tickets": ticketIds, "capacityOverride": overCapacity,
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return transactionId, ticketIds, nil
+}
@@ -0,0 +1,319 @@
+package routes
+
+import (
+	"database/sql"
+	"digitales-filmmanagement-backend/audit"
+	"digitales-filmmanagement-backend/globals"
+	"digitales-filmmanagement-backend/middleware"
+	"digitales-filmmanagement-backend/types"
+	"encoding/json"
+	"errors"
+	"github.com/blockloop/scan/v2"
+	"github.com/ggicci/httpin"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+func ShiftsRouter() http.Handler {
+	r := chi.NewRouter()
+	r.Use(middleware.RequireRole(types.Roles...))
+	r.With(httpin.NewInput(types.ScreeningsRequestInput{})).
+		Get("/understaffed", middleware.Handle(getUnderstaffedShifts))
+	return r
+}
+
+func getShifts(w http.ResponseWriter, r *http.Request) error {
+	screeningId := chi.URLParam(r, "screeningId")
+	if _, err := uuid.Parse(screeningId); err != nil {
+		return types.ErrorWithCode("INVALID_SCREENING_UUID")
+	}
+
+	rows, err := globals.SqlQueries.QueryContext(r.Context(), globals.Database, "get-shifts", screeningId)
+	if err != nil {
+		return err
+	}
+	var shifts []types.Shift
+	if err = scan.Rows(&shifts, rows); err != nil {
+		return err
+	}
+	if len(shifts) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+
+	// now add the volunteers to their shifts
+	rows, err = globals.SqlQueries.QueryContext(r.Context(), globals.Database, "get-shift-signups", screeningId)
+	if err != nil {
+		return err
+	}
+	var signups []types.ShiftSignup
+	if err = scan.Rows(&signups, rows); err != nil {
+		return err
+	}
+	for i := range shifts {
+		shifts[i].Volunteers = []types.ShiftSignup{}
+		for _, signup := range signups {
+			if signup.Shift == *shifts[i].ID {
+				shifts[i].Volunteers = append(shifts[i].Volunteers, signup)
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/json")
+	err = json.NewEncoder(w).Encode(shifts)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// setShifts replaces the roles and head-counts of the shifts of a screening.
+// Shifts which are not part of the template anymore are removed, unless a
+// volunteer already signed up for them
+func setShifts(w http.ResponseWriter, r *http.Request) error {
+	// access the request context
+	ctx := r.Context()
+
+	screeningId := chi.URLParam(r, "screeningId")
+	if _, err := uuid.Parse(screeningId); err != nil {
+		return types.ErrorWithCode("INVALID_SCREENING_UUID")
+	}
+
+	var templates []types.ShiftTemplate
+	if err := json.NewDecoder(r.Body).Decode(&templates); err != nil {
+		log.Warn().Err(err).Str("error", "INVALID_SHIFT_TEMPLATE").Msg("received invalid json payload")
+		return types.ErrorWithCode("INVALID_SHIFT_TEMPLATE")
+	}
+	roles := make([]string, 0, len(templates))
+	for i, template := range templates {
+		templates[i].Role = strings.TrimSpace(template.Role)
+		if templates[i].Role == "" || template.Required < 1 {
+			return types.ErrorWithCode("INVALID_SHIFT_TEMPLATE")
+		}
+		roles = append(roles, templates[i].Role)
+	}
+
+	tx, err := globals.Database.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	// the rollback is a no-op if the transaction has been committed already
+	defer tx.Rollback()
+
+	row, err := globals.SqlQueries.QueryRowContext(ctx, tx, "lock-screening", screeningId)
+	if err != nil {
+		return err
+	}
+	err = row.Scan(&screeningId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return types.ErrorWithCode("SCREENING_NOT_FOUND")
+	}
+	if err != nil {
+		return err
+	}
+
+	// now read the current shifts to record them in the audit log
+	rows, err := globals.SqlQueries.QueryContext(ctx, tx, "get-shifts", screeningId)
+	if err != nil {
+		return err
+	}
+	var shifts []types.Shift
+	if err = scan.Rows(&shifts, rows); err != nil {
+		return err
+	}
+	previousTemplates := make([]types.ShiftTemplate, 0, len(shifts))
+	for _, shift := range shifts {
+		previousTemplates = append(previousTemplates, types.ShiftTemplate{Role: shift.Role, Required: shift.Required})
+	}
+
+	// now check that no volunteer signed up for a removed shift
+	row, err = globals.SqlQueries.QueryRowContext(ctx, tx, "count-signups-of-removed-shifts", screeningId, pq.Array(roles))
+	if err != nil {
+		return err
+	}
+	var affectedSignups int
+	if err = row.Scan(&affectedSignups); err != nil {
+		return err
+	}
+	if affectedSignups > 0 {
+		return types.ErrorWithCode("SHIFT_HAS_SIGNUPS")
+	}
+
+	if _, err = globals.SqlQueries.ExecContext(ctx, tx, "delete-removed-shifts", screeningId, pq.Array(roles)); err != nil {
+		return err
+	}
+	for _, template := range templates {
+		_, err = globals.SqlQueries.ExecContext(ctx, tx, "upsert-shift", screeningId, template.Role, template.Required)
+		if err != nil {
+			return err
+		}
+	}
+	err = audit.Record(ctx, tx, audit.ActionUpdate, "shifts", screeningId, previousTemplates, templates)
+	if err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// signUpForShift signs the authenticated user up for a shift. The shift is
+// locked while the sign-up is stored to prevent more volunteers than required
+// from signing up at the same time
+func signUpForShift(w http.ResponseWriter, r *http.Request) error {
+	// access the request context
+	ctx := r.Context()
+
+	// now get the identity of the volunteer
+	userId, validUser := ctx.Value("userId").(string)
+	userName, _ := ctx.Value("user").(string)
+	if !validUser || userId == "" {
+		return types.ErrorWithCode("UNAUTHORIZED")
+	}
+
+	screeningId, shiftId, valid := shiftParameters(r)
+	if !valid {
+		return types.ErrorWithCode("INVALID_SHIFT_UUID")
+	}
+
+	tx, err := globals.Database.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	// the rollback is a no-op if the transaction has been committed already
+	defer tx.Rollback()
+
+	row, err := globals.SqlQueries.QueryRowContext(ctx, tx, "lock-shift", shiftId, screeningId)
+	if err != nil {
+		return err
+	}
+	var required, signedUp int
+	err = row.Scan(&required, &signedUp)
+	if errors.Is(err, sql.ErrNoRows) {
+		return types.ErrorWithCode("SHIFT_NOT_FOUND")
+	}
+	if err != nil {
+		return err
+	}
+	if signedUp >= required {
+		return types.ErrorWithCode("SHIFT_FULL")
+	}
+
+	result, err := globals.SqlQueries.ExecContext(ctx, tx, "insert-shift-signup", shiftId, userId, userName)
+	if err != nil {
+		return err
+	}
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if inserted == 0 {
+		return types.ErrorWithCode("SHIFT_ALREADY_SIGNED_UP")
+	}
+	err = audit.Record(ctx, tx, audit.ActionCreate, "shift-signup", shiftId, nil, map[string]interface{}{"volunteer": userId, "volunteerName": userName})
+	if err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusCreated)
+	return nil
+}
+
+// withdrawFromShift removes the sign-up of the authenticated user from a
+// shift
+func withdrawFromShift(w http.ResponseWriter, r *http.Request) error {
+	// access the request context
+	ctx := r.Context()
+
+	userId, validUser := ctx.Value("userId").(string)
+	if !validUser || userId == "" {
+		return types.ErrorWithCode("UNAUTHORIZED")
+	}
+
+	_, shiftId, valid := shiftParameters(r)
+	if !valid {
+		return types.ErrorWithCode("INVALID_SHIFT_UUID")
+	}
+
+	tx, err := globals.Database.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	// the rollback is a no-op if the transaction has been committed already
+	defer tx.Rollback()
+
+	result, err := globals.SqlQueries.ExecContext(ctx, tx, "delete-shift-signup", shiftId, userId)
+	if err != nil {
+		return err
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return types.ErrorWithCode("SHIFT_SIGNUP_NOT_FOUND")
+	}
+	err = audit.Record(ctx, tx, audit.ActionDelete, "shift-signup", shiftId, map[string]interface{}{"volunteer": userId}, nil)
+	if err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func getUnderstaffedShifts(w http.ResponseWriter, r *http.Request) error {
+	// access the request context
+	ctx := r.Context()
+	// now parse the parameters. if no start was supplied, only upcoming
+	// screenings are included
+	parameters := ctx.Value(httpin.Input).(*types.ScreeningsRequestInput)
+	from := time.Now()
+	if parameters.From != nil {
+		from = time.Unix(*parameters.From, 0)
+	}
+
+	rows, err := globals.SqlQueries.QueryContext(ctx, globals.Database, "get-understaffed-shifts", from)
+	if err != nil {
+		return err
+	}
+	var shifts []types.UnderstaffedShift
+	if err = scan.Rows(&shifts, rows); err != nil {
+		return err
+	}
+	if len(shifts) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "text/json")
+	err = json.NewEncoder(w).Encode(shifts)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// shiftParameters reads the screening and shift UUIDs from the request path
+// and reports if both are valid UUIDs
+func shiftParameters(r *http.Request) (screeningId string, shiftId string, valid bool) {
+	screeningId = chi.URLParam(r, "screeningId")
+	shiftId = chi.URLParam(r, "shiftId")
+	if _, err := uuid.Parse(screeningId); err != nil {
+		return "", "", false
+	}
+	if _, err := uuid.Parse(shiftId); err != nil {
+		return "", "", false
+	}
+	return screeningId, shiftId, true
+}
@@ -2,9 +2,9 @@ package routes
 
 import (
 	"digitales-filmmanagement-backend/globals"
+	"digitales-filmmanagement-backend/middleware"
 	"digitales-filmmanagement-backend/types"
 	"encoding/json"
-	"errors"
 	"github.com/blockloop/scan/v2"
 	"github.com/go-chi/chi/v5"
 	"time"
@@ -15,71 +15,95 @@ import (
 
 func StatisticsRouter() http.Handler {
 	r := chi.NewRouter()
+	r.Use(middleware.RequireRole(types.RoleTreasurer))
 	r.With(httpin.NewInput(types.StatisticsRequestInput{})).
-		Get("/items", itemStatistics)
+		Get("/items", middleware.Handle(itemStatistics))
+	r.With(httpin.NewInput(types.StatisticsRequestInput{})).
+		Get("/transactions", middleware.Handle(transactionReport))
 	return r
 }
 
-func itemStatistics(w http.ResponseWriter, r *http.Request) {
-	// access the request context and get the global error handler
+func itemStatistics(w http.ResponseWriter, r *http.Request) error {
+	// access the request context
 	ctx := r.Context()
-	nativeErrorHandler := ctx.Value("nativeErrorChannel").(chan error)
-	handledNativeError := ctx.Value("nativeErrorHandled").(chan bool)
 	// now parse the parameters
 	parameters := ctx.Value(httpin.Input).(*types.StatisticsRequestInput)
-	var from, until time.Time
-	switch {
-	case parameters.From == nil && parameters.Until == nil:
-		from = time.Now().Add(-24 * time.Hour)
-		until = time.Now()
-		break
-	case parameters.From != nil && parameters.Until == nil:
-		from = time.Unix(*parameters.From, 0)
-		until = time.Now()
-		break
-	case parameters.From == nil && parameters.Until != nil:
-		from = time.Time{}
-		until = time.Unix(*parameters.Until, 0)
-		break
-	case parameters.From != nil && parameters.Until != nil:
-		from = time.Unix(*parameters.From, 0)
-		until = time.Unix(*parameters.Until, 0)
-		break
-	default:
-		nativeErrorHandler <- errors.New("unmatched case")
-		<-handledNativeError
-		return
-	}
+	from, until := statisticsPeriod(parameters)
 
-	rows, err := globals.SqlQueries.Query(globals.Database, "get-article-statistics", from, until)
+	rows, err := globals.SqlQueries.QueryContext(ctx, globals.Database, "get-article-statistics", from, until)
 	if err != nil {
-		nativeErrorHandler <- err
-		<-handledNativeError
-		return
+		return err
 	}
 
 	var statistics []types.ArticleStatistic
 	// now parse the rows
 	err = scan.Rows(&statistics, rows)
 	if err != nil {
-		nativeErrorHandler <- err
-		<-handledNativeError
-		return
+		return err
 	}
 
 	if len(statistics) == 0 {
 		w.WriteHeader(204)
-		return
+		return nil
 	}
 
 	// now return the statistics
 	w.Header().Set("Content-Type", "text/json")
 	err = json.NewEncoder(w).Encode(statistics)
 	if err != nil {
-		// send error to the error handler
-		nativeErrorHandler <- err
-		// wait until the error was handled
-		<-handledNativeError
-		return
+		return err
+	}
+	return nil
+}
+
+// transactionReport lists the transactions booked in the requested period.
+// The responsible person is shown with their current name, unless the
+// transaction was booked before the identity of the users was stored
+func transactionReport(w http.ResponseWriter, r *http.Request) error {
+	// access the request context
+	ctx := r.Context()
+	// now parse the parameters
+	parameters := ctx.Value(httpin.Input).(*types.StatisticsRequestInput)
+	from, until := statisticsPeriod(parameters)
+
+	rows, err := globals.SqlQueries.QueryContext(ctx, globals.Database, "get-transactions", from, until)
+	if err != nil {
+		return err
+	}
+	var transactions []types.Transaction
+	if err = scan.Rows(&transactions, rows); err != nil {
+		return err
+	}
+	if len(transactions) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "text/json")
+	err = json.NewEncoder(w).Encode(transactions)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// statisticsPeriod returns the period requested in the parameters. If no
+// start is supplied, the period starts 24 hours ago or at the beginning of
+// the records if an end was supplied. If no end is supplied, the period ends
+// now
+func statisticsPeriod(parameters *types.StatisticsRequestInput) (from time.Time, until time.Time) {
+	switch {
+	case parameters.From == nil && parameters.Until == nil:
+		from = time.Now().Add(-24 * time.Hour)
+		until = time.Now()
+	case parameters.From != nil && parameters.Until == nil:
+		from = time.Unix(*parameters.From, 0)
+		until = time.Now()
+	case parameters.From == nil && parameters.Until != nil:
+		from = time.Time{}
+		until = time.Unix(*parameters.Until, 0)
+	default:
+		from = time.Unix(*parameters.From, 0)
+		until = time.Unix(*parameters.Until, 0)
 	}
+	return from, until
 }
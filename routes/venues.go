@@ -0,0 +1,99 @@
+package routes
+
+import (
+	"digitales-filmmanagement-backend/audit"
+	"digitales-filmmanagement-backend/globals"
+	"digitales-filmmanagement-backend/middleware"
+	"digitales-filmmanagement-backend/types"
+	"encoding/json"
+	"github.com/blockloop/scan/v2"
+	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"strings"
+)
+
+func VenuesRouter() http.Handler {
+	r := chi.NewRouter()
+	r.With(middleware.RequireRole(types.Roles...)).Get("/", middleware.Handle(getAllVenues))
+	r.With(middleware.RequireRole(types.RoleAdmin)).Post("/", middleware.Handle(newVenue))
+	return r
+}
+
+func getAllVenues(w http.ResponseWriter, r *http.Request) error {
+	// now try to get all venues from the database
+	rows, err := globals.SqlQueries.QueryContext(r.Context(), globals.Database, "get-venues")
+	if err != nil {
+		return err
+	}
+	var venues []types.Venue
+	if err = scan.Rows(&venues, rows); err != nil {
+		return err
+	}
+	if len(venues) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "text/json")
+	err = json.NewEncoder(w).Encode(venues)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func newVenue(w http.ResponseWriter, r *http.Request) error {
+	// access the request context
+	ctx := r.Context()
+
+	// now try and parse the request body
+	var venue types.Venue
+	if err := json.NewDecoder(r.Body).Decode(&venue); err != nil {
+		log.Warn().Err(err).Str("error", "INVALID_VENUE").Msg("received invalid json payload")
+		return types.ErrorWithCode("INVALID_VENUE")
+	}
+	// a venue needs a name and may not have a negative number of seats
+	var invalidFields []types.FieldError
+	if strings.TrimSpace(venue.Name) == "" {
+		invalidFields = append(invalidFields, types.FieldError{Field: "name", Code: "FIELD_EMPTY"})
+	}
+	if venue.Capacity < 0 {
+		invalidFields = append(invalidFields, types.FieldError{Field: "capacity", Code: "FIELD_NEGATIVE"})
+	}
+	if len(invalidFields) > 0 {
+		return types.ValidationError("INVALID_VENUE", invalidFields...)
+	}
+
+	tx, err := globals.Database.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	// the rollback is a no-op if the transaction has been committed already
+	defer tx.Rollback()
+
+	row, err := globals.SqlQueries.QueryRowContext(ctx, tx, "insert-venue", venue.Name, venue.Capacity)
+	if err != nil {
+		return err
+	}
+	var venueId string
+	if err = row.Scan(&venueId); err != nil {
+		log.Error().Err(err).Msg("error while inserting venue")
+		return err
+	}
+	venue.ID = &venueId
+	err = audit.Record(ctx, tx, audit.ActionCreate, "venue", venueId, nil, venue)
+	if err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "text/json")
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(venue)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to send created venue")
+	}
+	return nil
+}
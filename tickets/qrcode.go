@@ -0,0 +1,47 @@
+package tickets
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/skip2/go-qrcode"
+)
+
+// WritePNG renders the token as a QR code in the PNG format
+func WritePNG(w io.Writer, token string, size int) error {
+	code, err := qrcode.New(token, qrcode.Medium)
+	if err != nil {
+		return err
+	}
+	png, err := code.PNG(size)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(png)
+	return err
+}
+
+// WriteSVG renders the token as a QR code in the SVG format. Every dark module
+// of the code is drawn as a square with the size of one unit, therefore the
+// graphic can be scaled freely
+func WriteSVG(w io.Writer, token string) error {
+	code, err := qrcode.New(token, qrcode.Medium)
+	if err != nil {
+		return err
+	}
+	bitmap := code.Bitmap()
+	var path strings.Builder
+	for y, row := range bitmap {
+		for x, dark := range row {
+			if dark {
+				fmt.Fprintf(&path, "M%d %dh1v1h-1z", x, y)
+			}
+		}
+	}
+	_, err = fmt.Fprintf(w,
+		`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %[1]d %[1]d" shape-rendering="crispEdges">`+
+			`<rect width="%[1]d" height="%[1]d" fill="#fff"/><path d="%[2]s" fill="#000"/></svg>`,
+		len(bitmap), path.String())
+	return err
+}
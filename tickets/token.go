@@ -0,0 +1,76 @@
+package tickets
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"digitales-filmmanagement-backend/globals"
+)
+
+// Kind identifies the type of object a token has been issued for
+type Kind string
+
+const (
+	// KindTicket marks a token issued for a single ticket
+	KindTicket Kind = "t"
+	// KindReservation marks a token issued for a reservation
+	KindReservation Kind = "r"
+)
+
+// ErrInvalidToken is returned if a token is malformed or its signature does
+// not match the content
+var ErrInvalidToken = errors.New("invalid ticket token")
+
+// encoding is used for both parts of a token, since it produces strings which
+// can be used in URLs and QR codes without further escaping
+var encoding = base64.RawURLEncoding
+
+// Sign creates a token for the object with the supplied UUID. The token
+// contains the kind and the UUID of the object followed by a HMAC-SHA256
+// signature using the configured signing key
+func Sign(kind Kind, id string) string {
+	payload := string(kind) + ":" + id
+	return encoding.EncodeToString([]byte(payload)) + "." + encoding.EncodeToString(signature(payload))
+}
+
+// Verify checks the signature of the supplied token and returns the kind and
+// UUID of the object the token has been issued for
+func Verify(token string) (Kind, string, error) {
+	encodedPayload, encodedSignature, found := strings.Cut(strings.TrimSpace(token), ".")
+	if !found {
+		return "", "", ErrInvalidToken
+	}
+	payload, err := encoding.DecodeString(encodedPayload)
+	if err != nil {
+		return "", "", ErrInvalidToken
+	}
+	suppliedSignature, err := encoding.DecodeString(encodedSignature)
+	if err != nil {
+		return "", "", ErrInvalidToken
+	}
+	if !hmac.Equal(suppliedSignature, signature(string(payload))) {
+		return "", "", ErrInvalidToken
+	}
+
+	kind, id, found := strings.Cut(string(payload), ":")
+	if !found || (Kind(kind) != KindTicket && Kind(kind) != KindReservation) {
+		return "", "", ErrInvalidToken
+	}
+	if _, err := uuid.Parse(id); err != nil {
+		return "", "", ErrInvalidToken
+	}
+	return Kind(kind), id, nil
+}
+
+// signature calculates the HMAC-SHA256 of the payload using the configured
+// signing key
+func signature(payload string) []byte {
+	mac := hmac.New(sha256.New, []byte(*globals.Configuration.Tickets.SigningKey))
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,67 @@
+package tracing
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/qustavo/dotsql"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Queries wraps the sql queries loaded by dotsql and records a span for
+// every execution of a query. Only the executions using a context are
+// recorded, since the span would otherwise not be part of the trace of the
+// request
+type Queries struct {
+	*dotsql.DotSql
+}
+
+// QueryContext executes the named query and records the execution as span
+func (q Queries) QueryContext(ctx context.Context, db dotsql.QueryerContext, name string, args ...interface{}) (*sql.Rows, error) {
+	ctx, span := q.startSpan(ctx, name)
+	defer span.End()
+	rows, err := q.DotSql.QueryContext(ctx, db, name, args...)
+	recordError(span, err)
+	return rows, err
+}
+
+// QueryRowContext executes the named query and records the execution as
+// span. Errors of the query are only returned when scanning the row,
+// therefore they are not recorded in the span
+func (q Queries) QueryRowContext(ctx context.Context, db dotsql.QueryRowerContext, name string, args ...interface{}) (*sql.Row, error) {
+	ctx, span := q.startSpan(ctx, name)
+	defer span.End()
+	row, err := q.DotSql.QueryRowContext(ctx, db, name, args...)
+	recordError(span, err)
+	return row, err
+}
+
+// ExecContext executes the named statement and records the execution as span
+func (q Queries) ExecContext(ctx context.Context, db dotsql.ExecerContext, name string, args ...interface{}) (sql.Result, error) {
+	ctx, span := q.startSpan(ctx, name)
+	defer span.End()
+	result, err := q.DotSql.ExecContext(ctx, db, name, args...)
+	recordError(span, err)
+	return result, err
+}
+
+// startSpan starts the span of a query execution, using the name of the
+// query as span name
+func (q Queries) startSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	attributes := []attribute.KeyValue{semconv.DBOperation(name)}
+	if statement, err := q.Raw(name); err == nil {
+		attributes = append(attributes, semconv.DBStatement(statement))
+	}
+	return tracer.Start(ctx, "sql "+name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attributes...))
+}
+
+// recordError marks the span as failed if the query returned an error
+func recordError(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
@@ -0,0 +1,70 @@
+package tracing
+
+import (
+	"context"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+
+	"digitales-filmmanagement-backend/config"
+)
+
+// instrumentationName identifies the spans created by the backend itself
+const instrumentationName = "digitales-filmmanagement-backend"
+
+// tracer creates the spans of the database queries. It uses the global
+// tracer provider, therefore the spans are only exported after Start has
+// been called
+var tracer = otel.Tracer(instrumentationName)
+
+// Start configures the global tracer provider using the selected exporter
+// and enables the propagation of the W3C trace context. If no exporter has
+// been selected, the spans are discarded, but the trace context of incoming
+// requests is still passed on to the authorization servers.
+//
+// The returned function flushes the spans which have not been exported yet
+// and needs to be called before the backend exits
+func Start(ctx context.Context, c config.TracingConfiguration) (func(ctx context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if !c.IsEnabled() {
+		return func(ctx context.Context) error { return nil }, nil
+	}
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch *c.Exporter {
+	case config.TracingExporterOTLP:
+		var options []otlptracehttp.Option
+		if c.Endpoint != nil {
+			options = append(options, otlptracehttp.WithEndpoint(*c.Endpoint))
+		}
+		if c.Insecure {
+			options = append(options, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(ctx, options...)
+	case config.TracingExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	serviceResource, err := resource.Merge(resource.Default(),
+		resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(*c.ServiceName)))
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(serviceResource),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(*c.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
@@ -0,0 +1,34 @@
+package types
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AuditEntry reflects a change recorded in the audit log
+type AuditEntry struct {
+	// ID contains the sequential number of the entry
+	ID int64 `json:"id"`
+	// OccurredAt contains the time at which the change was made
+	OccurredAt time.Time `json:"occurredAt"`
+	// Actor contains the subject of the user who made the change. It is
+	// empty if a locked device made the change
+	Actor string `json:"actor"`
+	// ActorName contains the display name of the user at the time of the
+	// change
+	ActorName string `json:"actorName"`
+	// Device contains the device used for the change, if any
+	Device *string `json:"device"`
+	// RequestID contains the id of the request which made the change
+	RequestID string `json:"requestId"`
+	// Action contains the kind of the change: create, update or delete
+	Action string `json:"action"`
+	// Entity contains the kind of the changed entity, e.g. "transaction"
+	Entity string `json:"entity"`
+	// EntityID contains the identifier of the changed entity
+	EntityID string `json:"entityId"`
+	// Before contains the state of the entity before the change
+	Before json.RawMessage `json:"before"`
+	// After contains the state of the entity after the change
+	After json.RawMessage `json:"after"`
+}
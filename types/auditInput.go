@@ -0,0 +1,11 @@
+package types
+
+type AuditRequestInput struct {
+	From     *int64  `in:"query=from"`
+	Until    *int64  `in:"query=until"`
+	Entity   *string `in:"query=entity"`
+	EntityID *string `in:"query=entityId"`
+	Actor    *string `in:"query=actor"`
+	Action   *string `in:"query=action"`
+	Limit    *int    `in:"query=limit"`
+}
@@ -0,0 +1,11 @@
+package types
+
+// CacheStatistics contains the counters of an in-memory cache
+type CacheStatistics struct {
+	// Hits contains the number of lookups answered from the cache
+	Hits uint64 `json:"hits"`
+	// Misses contains the number of lookups which were not cached
+	Misses uint64 `json:"misses"`
+	// Entries contains the current number of cached entries
+	Entries int `json:"entries"`
+}
@@ -0,0 +1,6 @@
+package types
+
+type CalendarRequestInput struct {
+	Venue    *string `in:"query=venue"`
+	Category *string `in:"query=category"`
+}
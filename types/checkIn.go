@@ -0,0 +1,21 @@
+package types
+
+// CheckInRequest contains the request body sent by a door device after
+// scanning the QR code of a ticket or reservation
+type CheckInRequest struct {
+	// Token contains the signed token read from the QR code
+	Token string `json:"token"`
+}
+
+// CheckInStatus reports the number of guests that already entered a screening
+type CheckInStatus struct {
+	// Screening contains the UUID of the screening
+	Screening string `json:"screening" db:"screening"`
+	// Admitted contains the number of guests admitted by the scanned token. It
+	// is only set in responses to a check-in
+	Admitted int `json:"admitted,omitempty" db:"-"`
+	// CheckedIn contains the number of tickets already used for the screening
+	CheckedIn int `json:"checkedIn" db:"checked_in"`
+	// SoldTickets contains the number of tickets sold for the screening
+	SoldTickets int `json:"soldTickets" db:"sold_tickets"`
+}
@@ -0,0 +1,68 @@
+package types
+
+import "time"
+
+// Device reflects a terminal like the bar tablet which authenticates with a
+// long-lived key instead of an OpenID Connect session. A device is bound to a
+// single register and needs to be unlocked by a staff member using a PIN.
+// Transactions booked on the device are attributed to the staff member who
+// entered their PIN last
+type Device struct {
+	// ID contains the UUID used to identify the device in API calls
+	ID *string `json:"id" db:"id"`
+	// Name contains the name used to identify the device in frontend
+	// applications
+	Name string `json:"name" db:"name"`
+	// Register contains the UUID of the register the device is bound to
+	Register string `json:"register" db:"register"`
+	// Roles contains the roles granted to the device
+	Roles []Role `json:"roles" db:"-"`
+	// CreatedBy contains the name of the admin that registered the device
+	CreatedBy string `json:"createdBy" db:"created_by"`
+	// CreatedAt contains the time at which the device was registered
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+	// RevokedAt contains the time at which the key of the device was revoked
+	RevokedAt *time.Time `json:"revokedAt" db:"revoked_at"`
+	// UnlockedBy contains the name of the staff member that unlocked the
+	// device
+	UnlockedBy *string `json:"unlockedBy" db:"unlocked_by_name"`
+	// UnlockedAt contains the time at which the device was unlocked
+	UnlockedAt *time.Time `json:"unlockedAt" db:"unlocked_at"`
+}
+
+// DeviceCredentials is returned once after the registration of a device and
+// contains the key the device uses to authenticate
+type DeviceCredentials struct {
+	Device
+	// Key contains the device key. It cannot be retrieved again
+	Key string `json:"key"`
+}
+
+// DeviceUnlock contains the request body used to unlock a device or to
+// switch its active staff member
+type DeviceUnlock struct {
+	// User contains the subject of the staff member, as selected from the
+	// list of staff members with a PIN
+	User string `json:"user"`
+	// Pin contains the PIN of the staff member. It is only checked against
+	// the PIN of the selected staff member
+	Pin string `json:"pin"`
+}
+
+// ActiveStaff contains the staff member who is currently using a device. It
+// is also used to list the staff members who may unlock a device
+type ActiveStaff struct {
+	// User contains the subject of the staff member's OpenID Connect identity
+	User string `json:"user" db:"subject"`
+	// Name contains the name of the staff member
+	Name string `json:"name" db:"name"`
+	// Roles contains the roles the device grants while the staff member is
+	// using it. It is only set after unlocking the device
+	Roles []Role `json:"roles,omitempty" db:"-"`
+}
+
+// StaffPin contains the request body used by a staff member to set a PIN
+type StaffPin struct {
+	// Pin contains the new PIN
+	Pin string `json:"pin"`
+}
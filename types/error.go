@@ -2,6 +2,7 @@ package types
 
 import (
 	"net/http"
+	"strings"
 )
 
 // APIError is the default response layout for an error that occurred during
@@ -21,6 +22,36 @@ type APIError struct {
 	// HttpStatusText contains the description of the HttpStatusCode to allow
 	// humans to understand the numeric http code
 	HttpStatusText string `json:"httpError"`
+	// Translations contains the title and the description of the error in
+	// every configured language, using the language tag as key
+	Translations map[string]ErrorTranslation `json:"translations,omitempty"`
+}
+
+// ErrorTranslation contains the human-readable texts of an APIError in a
+// single language
+type ErrorTranslation struct {
+	// Title contains the translated error title
+	Title string `json:"title"`
+	// Description contains the translated error description
+	Description string `json:"description"`
+}
+
+// HasTranslation reports if the title and the description of the error are
+// available in the language
+func (e *APIError) HasTranslation(language string) bool {
+	translation, exists := e.Translations[language]
+	return exists && strings.TrimSpace(translation.Title) != "" && strings.TrimSpace(translation.Description) != ""
+}
+
+// Localize returns a copy of the error using the title and the description
+// in the supplied language. If the error has not been translated to the
+// language, the copy keeps the current title and description
+func (e APIError) Localize(language string) APIError {
+	if translation, exists := e.Translations[language]; exists {
+		e.ErrorTitle = translation.Title
+		e.ErrorDescription = translation.Description
+	}
+	return e
 }
 
 // InferHttpStatusText takes the already configured HTTP Status Code and infers
@@ -33,16 +64,67 @@ func (e *APIError) InferHttpStatusText() {
 // WrapError takes a native golang error as parameter and wraps it into a
 // WISdoMError. The WISdoMError instance will overwrite every field already
 // present on the error and set the status code to 500 to indicate a internal
-// error occurred. It optionally takes a service name as argument, if multiple
-// names are supplied they are joined together using a dot (.)
+// error occurred. The description only contains a generic message, since the
+// message of the error may contain details of the database which should not
+// be sent to the clients
 func (e *APIError) WrapError(err error) {
 	// now build full the full error code used for wrapping internal errors
 	e.ErrorCode = "INTERNAL_ERROR"
 	// set the title to a generic error title
 	e.ErrorTitle = "Internal Error in Backend"
-	// set the error description to the external error
-	e.ErrorDescription = err.Error()
+	// set the error description to a generic description
+	e.ErrorDescription = "An internal error occurred while handling the request"
 	// set the http code to 500 and infer the text from this
 	e.HttpStatusCode = http.StatusInternalServerError
 	e.HttpStatusText = http.StatusText(e.HttpStatusCode)
 }
+
+// CodedError is returned by the http handlers to render the predefined
+// APIError with the code. The optional wrapped error contains the cause of
+// the error and is only used for logging
+type CodedError struct {
+	// Code contains the code of the predefined APIError
+	Code string
+	// Err contains the cause of the error
+	Err error
+	// Fields contains the invalid fields of the request, if any
+	Fields []FieldError
+}
+
+// ErrorWithCode returns an error which is rendered as the predefined
+// APIError with the supplied code
+func ErrorWithCode(code string) error {
+	return &CodedError{Code: code}
+}
+
+// ValidationError returns an error which is rendered as the predefined
+// APIError with the supplied code and lists the invalid fields of the request
+func ValidationError(code string, fields ...FieldError) error {
+	return &CodedError{Code: code, Fields: fields}
+}
+
+// Problem converts the APIError into a problem details object
+func (e *APIError) Problem() Problem {
+	status := e.HttpStatusCode
+	if status == 0 {
+		status = http.StatusInternalServerError
+	}
+	return Problem{
+		Type:   ProblemTypePrefix + e.ErrorCode,
+		Title:  e.ErrorTitle,
+		Status: status,
+		Detail: e.ErrorDescription,
+		Code:   e.ErrorCode,
+	}
+}
+
+func (e *CodedError) Error() string {
+	if e.Err != nil {
+		return e.Code + ": " + e.Err.Error()
+	}
+	return e.Code
+}
+
+func (e *CodedError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,29 @@
+package types
+
+// HealthStatusUp and HealthStatusDown are the states reported by the health
+// endpoints
+const (
+	HealthStatusUp   = "up"
+	HealthStatusDown = "down"
+)
+
+// HealthStatus is the response of the liveness and readiness endpoints
+type HealthStatus struct {
+	// Status contains "up" if the backend and every checked dependency are
+	// available, else "down"
+	Status string `json:"status"`
+	// Checks contains the status of every checked dependency using the name
+	// of the dependency as key. The liveness endpoint does not check any
+	// dependencies
+	Checks map[string]DependencyStatus `json:"checks,omitempty"`
+}
+
+// DependencyStatus reports the result of the check of a single dependency
+type DependencyStatus struct {
+	// Status contains "up" if the dependency is available, else "down"
+	Status string `json:"status"`
+	// Latency contains the duration of the check in milliseconds
+	Latency int64 `json:"latency"`
+	// Detail contains the reason why the dependency is unavailable
+	Detail string `json:"detail,omitempty"`
+}
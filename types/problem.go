@@ -0,0 +1,39 @@
+package types
+
+// ProblemTypePrefix is prepended to the error code to build the type of a
+// problem
+const ProblemTypePrefix = "urn:filmmanagement:error:"
+
+// Problem is the RFC 7807 problem details object rendered for every error
+// response. The code of the predefined APIError is kept as extension member,
+// since the frontends use it to identify the error
+//
+// See also: https://www.rfc-editor.org/rfc/rfc7807
+type Problem struct {
+	// Type contains a URI identifying the kind of the problem
+	Type string `json:"type"`
+	// Title contains a short human-readable summary of the problem
+	Title string `json:"title"`
+	// Status contains the http status code of the response
+	Status int `json:"status"`
+	// Detail contains a human-readable explanation of the problem
+	Detail string `json:"detail,omitempty"`
+	// Instance contains the id of the request which caused the problem
+	Instance string `json:"instance,omitempty"`
+	// Code contains the code of the predefined APIError
+	Code string `json:"code"`
+	// Errors contains the fields of the request body which are invalid
+	Errors []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError describes an invalid field of a request
+type FieldError struct {
+	// Field contains the name of the invalid field as used in the request
+	Field string `json:"field"`
+	// Code contains the code of the predefined APIError describing why the
+	// field is invalid
+	Code string `json:"code"`
+	// Detail contains the translated description of the predefined APIError.
+	// It is set while the error is rendered
+	Detail string `json:"detail"`
+}
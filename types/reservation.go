@@ -0,0 +1,36 @@
+package types
+
+import "time"
+
+// Reservation reflects seats reserved by a guest for a screening. The seats
+// are held until shortly before the screening and are converted into tickets
+// once the guest pays at the door
+type Reservation struct {
+	// ID contains the UUID used to identify the reservation in API calls
+	ID *string `json:"id" db:"id"`
+	// Screening contains the UUID of the screening the seats are reserved for
+	Screening string `json:"screening" db:"screening"`
+	// Name contains the name of the guest that reserved the seats
+	Name string `json:"name" db:"name"`
+	// Email contains the email address of the guest that reserved the seats
+	Email string `json:"email" db:"email"`
+	// Seats contains the number of reserved seats
+	Seats int `json:"seats" db:"seats"`
+	// Status contains the current state of the reservation. It is one of
+	// "held", "claimed", "released" and "cancelled"
+	Status string `json:"status" db:"status"`
+	// CreatedAt contains the time at which the reservation was made
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+	// Transaction contains the UUID of the transaction in which the
+	// reservation was paid
+	Transaction *string `json:"transaction" db:"transaction"`
+}
+
+// ReservationClaim contains the request body used to convert a reservation
+// into a ticket sale at the door
+type ReservationClaim struct {
+	// Register contains the UUID of the register in which the tickets are paid
+	Register string `json:"register"`
+	// Total contains the total amount paid for the tickets
+	Total float64 `json:"amount"`
+}
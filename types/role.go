@@ -0,0 +1,32 @@
+package types
+
+// Role is a backend role granting access to a set of API routes. The roles
+// of a user are derived from the groups in the OpenID Connect userinfo
+type Role string
+
+const (
+	// RoleAdmin grants access to every route, including the management of
+	// venues, screenings and shifts and booking tickets beyond the capacity
+	RoleAdmin Role = "admin"
+	// RoleTreasurer grants access to the registers and the statistics
+	RoleTreasurer Role = "treasurer"
+	// RoleBar grants access to the registers to sell articles at the bar
+	RoleBar Role = "bar"
+	// RoleDoor grants access to ticket sales, reservations and the check-in
+	RoleDoor Role = "door"
+	// RoleVolunteer grants access to the programme and the shift planning
+	RoleVolunteer Role = "volunteer"
+)
+
+// Roles contains every role known to the backend
+var Roles = []Role{RoleAdmin, RoleTreasurer, RoleBar, RoleDoor, RoleVolunteer}
+
+// IsKnown reports if the role is known to the backend
+func (r Role) IsKnown() bool {
+	for _, role := range Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,43 @@
+package types
+
+import "time"
+
+// Screening reflects a single screening stored in the database
+type Screening struct {
+	// ID contains the UUID used to identify the screening in API calls
+	ID *string `json:"id" db:"id"`
+	// Title contains the title of the screened movie or event
+	Title string `json:"title" db:"title"`
+	// StartsAt contains the time at which the screening starts
+	StartsAt time.Time `json:"startsAt" db:"starts_at"`
+	// Venue contains the UUID of the venue in which the screening takes place
+	Venue string `json:"venue" db:"venue"`
+	// Capacity contains an optional capacity for this screening which takes
+	// precedence over the capacity of the venue
+	Capacity *int `json:"capacity" db:"capacity"`
+	// WordPressPostID contains the optional id of the post describing the
+	// screening on the public website
+	WordPressPostID *int64 `json:"wordpressPostId" db:"wp_post_id"`
+	// EffectiveCapacity contains the capacity which is enforced for ticket
+	// bookings
+	EffectiveCapacity int `json:"effectiveCapacity" db:"effective_capacity"`
+	// SoldTickets contains the number of tickets already sold for the screening
+	SoldTickets int `json:"soldTickets" db:"sold_tickets"`
+	// ReservedSeats contains the number of seats held by reservations which
+	// have not been claimed yet
+	ReservedSeats int `json:"reservedSeats" db:"reserved_seats"`
+}
+
+// TicketBooking contains the request body used to book tickets for a
+// screening
+type TicketBooking struct {
+	// Register contains the UUID of the register in which the tickets are paid
+	Register string `json:"register"`
+	// Count contains the number of tickets that should be booked
+	Count int `json:"count"`
+	// Total contains the total amount paid for the tickets
+	Total float64 `json:"amount"`
+	// OverrideCapacity allows staff with the admin role to book tickets
+	// although the screening is already sold out
+	OverrideCapacity bool `json:"overrideCapacity"`
+}
@@ -0,0 +1,14 @@
+package types
+
+// ScreeningAttendance contains the aggregated attendance and ticket revenue of
+// a finished screening which is written back to its WordPress post
+type ScreeningAttendance struct {
+	// Screening contains the UUID of the screening
+	Screening string `db:"screening"`
+	// WordPressPostID contains the id of the post describing the screening
+	WordPressPostID int64 `db:"wp_post_id"`
+	// Attendance contains the number of tickets sold for the screening
+	Attendance int `db:"attendance"`
+	// Revenue contains the sum of all ticket transactions of the screening
+	Revenue float64 `db:"revenue"`
+}
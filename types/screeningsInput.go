@@ -0,0 +1,5 @@
+package types
+
+type ScreeningsRequestInput struct {
+	From *int64 `in:"query=from"`
+}
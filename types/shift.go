@@ -0,0 +1,59 @@
+package types
+
+import "time"
+
+// Shift reflects a duty like the door, the bar or the projection which needs
+// to be staffed by a number of volunteers during a screening
+type Shift struct {
+	// ID contains the UUID used to identify the shift in API calls
+	ID *string `json:"id" db:"id"`
+	// Screening contains the UUID of the screening the shift belongs to
+	Screening string `json:"screening" db:"screening"`
+	// Role contains the name of the duty, e.g. "door" or "bar"
+	Role string `json:"role" db:"role"`
+	// Required contains the number of volunteers needed for the shift
+	Required int `json:"required" db:"required"`
+	// Volunteers contains the volunteers which signed up for the shift
+	Volunteers []ShiftSignup `json:"volunteers" db:"-"`
+}
+
+// ShiftSignup reflects a volunteer that signed up for a shift
+type ShiftSignup struct {
+	// Shift contains the UUID of the shift
+	Shift string `json:"-" db:"shift"`
+	// Volunteer contains the subject of the volunteer's OpenID Connect identity
+	Volunteer string `json:"id" db:"volunteer"`
+	// VolunteerName contains the name of the volunteer at the time of the
+	// sign-up
+	VolunteerName string `json:"name" db:"volunteer_name"`
+	// SignedUpAt contains the time of the sign-up
+	SignedUpAt time.Time `json:"signedUpAt" db:"signed_up_at"`
+}
+
+// ShiftTemplate contains a role and its head-count used to set up the
+// shifts of a screening
+type ShiftTemplate struct {
+	// Role contains the name of the duty
+	Role string `json:"role"`
+	// Required contains the number of volunteers needed for the duty
+	Required int `json:"required"`
+}
+
+// UnderstaffedShift reflects a shift of an upcoming screening which still
+// needs volunteers
+type UnderstaffedShift struct {
+	// Screening contains the UUID of the screening
+	Screening string `json:"screening" db:"screening"`
+	// Title contains the title of the screening
+	Title string `json:"title" db:"title"`
+	// StartsAt contains the start of the screening
+	StartsAt time.Time `json:"startsAt" db:"starts_at"`
+	// Shift contains the UUID of the shift
+	Shift string `json:"shift" db:"shift"`
+	// Role contains the name of the duty
+	Role string `json:"role" db:"role"`
+	// Required contains the number of volunteers needed for the shift
+	Required int `json:"required" db:"required"`
+	// SignedUp contains the number of volunteers which already signed up
+	SignedUp int `json:"signedUp" db:"signed_up"`
+}
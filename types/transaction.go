@@ -1,16 +1,31 @@
 package types
 
+import "time"
+
 type Transaction struct {
+	// ID contains the UUID used to identify the transaction
+	ID *string `json:"id,omitempty" db:"id"`
 	// Title contains the title of the transaction
 	Title string `json:"title" db:"title"`
 	// Description contains a more in depth description of the transaction
 	Description *string `json:"description" db:"description"`
 	// Amount contains the amount of the transaction in euros
 	Amount float64 `json:"amount" db:"amount"`
-	// By contains the full name of the person responsible for this transaction
+	// By contains the display name of the person responsible for this
+	// transaction at the time of the transaction. Reports replace it with the
+	// current name of the user
 	By string `json:"by" db:"by"`
+	// BySubject contains the subject of the OpenID Connect identity of the
+	// person responsible for this transaction. It is not set for transactions
+	// booked before the subject was stored
+	BySubject *string `json:"bySubject" db:"by_subject"`
 	// Register contains the register in which th transaction took place
 	Register string `json:"register" db:"register"`
+	// Device contains the device on which the transaction took place, if it
+	// was not booked using an OpenID Connect session
+	Device *string `json:"device" db:"device"`
+	// CreatedAt contains the time at which the transaction was booked
+	CreatedAt *time.Time `json:"createdAt,omitempty" db:"created_at"`
 	// storedInDb contains a boolean indicator to stop writing the transaction
 	// multiple times into the database
 	storedInDb bool
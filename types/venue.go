@@ -0,0 +1,13 @@
+package types
+
+// Venue reflects a hall or other location in which screenings take place
+type Venue struct {
+	// ID contains the UUID used to identify the venue in API calls
+	ID *string `json:"id" db:"id"`
+	// Name contains the name used to identify the venue in frontend applications
+	Name string `json:"name" db:"name"`
+	// Capacity contains the number of seats available in the venue. It is used
+	// as the capacity of every screening in this venue which does not set its
+	// own capacity
+	Capacity int `json:"capacity" db:"capacity"`
+}
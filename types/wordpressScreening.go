@@ -0,0 +1,21 @@
+package types
+
+// WordPressScreening contains a screening as published in a WordPress post
+type WordPressScreening struct {
+	// PostID contains the id of the post describing the screening
+	PostID int64 `db:"ID"`
+	// Title contains the title of the post
+	Title string `db:"post_title"`
+	// Excerpt contains the excerpt of the post
+	Excerpt string `db:"post_excerpt"`
+	// Modified contains the time of the last modification of the post in UTC
+	// as written by WordPress
+	Modified string `db:"post_modified_gmt"`
+	// Start contains the raw value of the post meta holding the start
+	Start string `db:"start"`
+	// Venue contains the name of the venue of the screening
+	Venue *string `db:"venue"`
+	// Categories contains the names of the categories of the post separated
+	// by line breaks
+	Categories *string `db:"categories"`
+}